@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+)
+
+// stripANSI removes ANSI escape sequences from s: CSI sequences such as
+// colors, OSC sequences such as hyperlinks (ended by BEL or ESC \), and
+// two-character escapes. Everything else is kept.
+func stripANSI(s string) string {
+	if !strings.ContainsRune(s, '\033') {
+		return s
+	}
+	var b strings.Builder
+	inEscape, inOSC := false, false
+	var prev rune
+	for _, r := range s {
+		switch {
+		case inOSC:
+			if r == '\a' || (r == '\\' && prev == '\033') {
+				inOSC = false
+			}
+		case inEscape:
+			if r == ']' && prev == '\033' {
+				inEscape, inOSC = false, true
+			} else if prev == '\033' && r >= '0' && r <= '~' && r != '[' || prev != '\033' && r >= '@' && r <= '~' {
+				// A two-character escape ends at any final byte; a CSI
+				// sequence only at one from '@' on.
+				inEscape = false
+			}
+		case r == '\033':
+			inEscape = true
+		default:
+			b.WriteRune(r)
+		}
+		prev = r
+	}
+	return b.String()
+}
+
+// copyStripped is -strip-ansi: it copies in to out a line at a time with
+// escape sequences removed, so it can follow a running watch.
+func copyStripped(in io.Reader, out io.Writer) error {
+	r := bufio.NewReader(in)
+	for {
+		line, err := r.ReadString('\n')
+		if line != "" {
+			if _, werr := io.WriteString(out, stripANSI(line)); werr != nil {
+				return werr
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStripANSI(t *testing.T) {
+	for in, want := range map[string]string{
+		"plain 17.1 (21B74)":                                  "plain 17.1 (21B74)",
+		"\033[31miOS\033[0m 17.1":                             "iOS 17.1",
+		"\033[2;3;36m~17.2\033[0m":                            "~17.2",
+		"\033]8;;https://ipsw.me/iOS\033\\17.1\033]8;;\033\\": "17.1",
+		"\033]8;;https://ipsw.me/iOS\a17.1\033]8;;\a":         "17.1",
+		"\033(Bascii \033=keypad":                             "ascii keypad",
+		"▌ iOS\033[K\n":                                       "▌ iOS\n",
+	} {
+		if got := stripANSI(in); got != want {
+			t.Errorf("stripANSI(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	// A colored table stripped of its escapes is the plain one.
+	items := loadFixture(t, "timeline.rss")
+	colored := plainOptions(80)
+	colored.Color = true
+	if got, want := stripANSI(renderTableString(items, colored)), renderTableString(items, plainOptions(80)); got != want {
+		t.Errorf("stripped colored table:\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCopyStripped(t *testing.T) {
+	var out strings.Builder
+	in := "\033[1mone\033[0m\ntwo\n\033[32mthree without newline"
+	if err := copyStripped(strings.NewReader(in), &out); err != nil {
+		t.Fatal(err)
+	}
+	if want := "one\ntwo\nthree without newline"; out.String() != want {
+		t.Errorf("copyStripped = %q, want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"unicode"
+)
+
+// auditExamples is how many titles -audit lists for each parsing gap.
+const auditExamples = 3
+
+// auditCheck is one heuristic of normalizeItem that -audit counts. For
+// gaps, the items it didn't apply to point at titles the parser can't
+// handle, so a few of them are listed.
+type auditCheck struct {
+	name  string
+	gap   bool
+	match func(Item) bool
+}
+
+var auditChecks = []auditCheck{
+	{"platform recognized", true, func(it Item) bool { return it.UnknownPlatform == "" }},
+	{"version found", true, func(it Item) bool { return it.Version != "" }},
+	{"build in parentheses", true, func(it Item) bool { return it.Build != "" }},
+	{`device split on " for "`, true, func(it Item) bool { return it.RawDevice != "" }},
+	{"date parsed", true, func(it Item) bool { return dateKnown(it.PubDate) }},
+	{"notes found", false, func(it Item) bool {
+		for _, r := range it.Notes {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return true
+			}
+		}
+		return false
+	}},
+	{"several devices", false, func(it Item) bool { return len(it.Devices) > 1 }},
+	{"pre-release keyword", false, func(it Item) bool { return it.PreRelease }},
+	{"security mentioned", false, func(it Item) bool { return it.SecurityContent }},
+}
+
+type auditResult struct {
+	Name    string `json:"name"`
+	Matched int    `json:"matched"`
+	// Missed holds example titles the check didn't match, for gaps only.
+	Missed []string `json:"missed,omitempty"`
+}
+
+type auditReport struct {
+	Items      int           `json:"items"`
+	Heuristics []auditResult `json:"heuristics"`
+}
+
+func auditItems(items []Item) auditReport {
+	report := auditReport{Items: len(items), Heuristics: []auditResult{}}
+	for _, check := range auditChecks {
+		res := auditResult{Name: check.name}
+		for _, it := range items {
+			switch {
+			case check.match(it):
+				res.Matched++
+			case check.gap && len(res.Missed) < auditExamples:
+				res.Missed = append(res.Missed, it.Title)
+			}
+		}
+		report.Heuristics = append(report.Heuristics, res)
+	}
+	return report
+}
+
+// runAudit normalizes every item of the feeds, before any filter, and
+// reports how often each parsing heuristic applied (-audit), with example
+// titles where the parser came up short.
+func runAudit(cfg Config) {
+	f := newFetcher(cfg)
+	items, err := loadFeeds(f, cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitCode(err))
+	}
+	report := auditItems(items)
+	if cfg.Format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	} else {
+		err = renderAudit(report, os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "write error:", err)
+		os.Exit(exitError)
+	}
+}
+
+func renderAudit(report auditReport, out io.Writer) error {
+	if _, err := fmt.Fprintf(out, "%d items\n", report.Items); err != nil {
+		return err
+	}
+	for _, res := range report.Heuristics {
+		percent := 0
+		if report.Items > 0 {
+			percent = res.Matched * 100 / report.Items
+		}
+		if _, err := fmt.Fprintf(out, "  %-24s %5d  %3d%%\n", res.Name, res.Matched, percent); err != nil {
+			return err
+		}
+		for _, title := range res.Missed {
+			if _, err := fmt.Fprintf(out, "      missed: %s\n", sanitize(title)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAuditFixture(t *testing.T) {
+	report := auditItems(loadFixture(t, "timeline.rss"))
+	want := map[string]int{
+		"platform recognized":     5,
+		"version found":           5,
+		"build in parentheses":    5,
+		`device split on " for "`: 4,
+		"date parsed":             5,
+		"notes found":             2,
+		"several devices":         1,
+		"pre-release keyword":     1,
+		"security mentioned":      1,
+	}
+	if report.Items != 5 || len(report.Heuristics) != len(want) {
+		t.Fatalf("audit of %d items with %d heuristics, want 5 and %d", report.Items, len(report.Heuristics), len(want))
+	}
+	for _, res := range report.Heuristics {
+		if res.Matched != want[res.Name] {
+			t.Errorf("%s: %d matched, want %d", res.Name, res.Matched, want[res.Name])
+		}
+	}
+	if missed := report.Heuristics[3].Missed; len(missed) != 1 || missed[0] != "macOS 14.2 beta 2 (23C5041e) has been released" {
+		t.Errorf("device split missed %q, want the macOS beta", missed)
+	}
+
+	var b strings.Builder
+	if err := renderAudit(report, &b); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "audit", b.String())
+}
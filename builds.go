@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// buildTrain is the leading part of an Apple build number: the major
+// number and the letter after it, such as 21 and 'B' for 21B74. Trains
+// order by number, then letter, so 21A < 21B < 22A.
+type buildTrain struct {
+	major  int
+	letter byte
+}
+
+// parseBuildTrain reads the train from a build or a bare train like "21A".
+func parseBuildTrain(build string) (buildTrain, bool) {
+	s := strings.ToUpper(strings.TrimSpace(build))
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	if i == 0 || i == len(s) || s[i] < 'A' || s[i] > 'Z' {
+		return buildTrain{}, false
+	}
+	major, err := strconv.Atoi(s[:i])
+	if err != nil {
+		return buildTrain{}, false
+	}
+	return buildTrain{major: major, letter: s[i]}, true
+}
+
+func (t buildTrain) after(u buildTrain) bool {
+	if t.major != u.major {
+		return t.major > u.major
+	}
+	return t.letter > u.letter
+}
+
+// parseTrainFilters reads -new-since-build: comma-separated trains, each
+// either bare ("21A", for every platform) or for one platform
+// ("macos=23B"). A platform entry wins over a bare one.
+func parseTrainFilters(entries []string) (map[string]buildTrain, error) {
+	return parsePlatformMap("new-since-build", entries, "a train like 21A or platform=21A", parseBuildTrain)
+}
+
+// filterNewTrains keeps items whose build train is newer than the one given
+// for their platform, or the "*" train. Platforms without a train are kept
+// as they are; items whose build has no train are dropped, since they can't
+// be placed.
+func filterNewTrains(items []Item, trains map[string]buildTrain) []Item {
+	return filterPerPlatform(items, trains, func(it Item, since buildTrain) bool {
+		train, ok := parseBuildTrain(it.Build)
+		return ok && train.after(since)
+	})
+}
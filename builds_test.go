@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildTrainOrder(t *testing.T) {
+	train := func(s string) buildTrain {
+		t.Helper()
+		tr, ok := parseBuildTrain(s)
+		if !ok {
+			t.Fatalf("parseBuildTrain(%q) failed", s)
+		}
+		return tr
+	}
+	ordered := []string{"20G75", "21A", "21A329", "21B", "21B91", "21C5046c", "22A"}
+	for i := 1; i < len(ordered); i++ {
+		a, b := train(ordered[i-1]), train(ordered[i])
+		if a == b {
+			continue
+		}
+		if !b.after(a) || a.after(b) {
+			t.Errorf("%s should come after %s", ordered[i], ordered[i-1])
+		}
+	}
+	if train("21a329") != train("21A") {
+		t.Error("train letters should ignore case")
+	}
+	for _, bad := range []string{"", "A21", "21", "21-", "beta"} {
+		if _, ok := parseBuildTrain(bad); ok {
+			t.Errorf("parseBuildTrain(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestFilterNewTrains(t *testing.T) {
+	items := loadFixture(t, "timeline.rss")
+	trains, err := parseTrainFilters(splitList("21B,macos=23C,tvos=22A"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, it := range filterNewTrains(items, trains) {
+		got = append(got, it.Build)
+	}
+	// iOS 21B91 and iPadOS 21B74 are in 21B itself; macOS 23C5041e is in
+	// 23C; tvOS 21K69 is older than 22A. Only watchOS 21S71 is newer.
+	if strings.Join(got, " ") != "21S71" {
+		t.Errorf("filterNewTrains = %v, want [21S71]", got)
+	}
+
+	for _, bad := range []string{"ios=", "=21A", "21", "ios=latest"} {
+		if _, err := parseTrainFilters([]string{bad}); err == nil {
+			t.Errorf("parseTrainFilters(%q): no error", bad)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// feedCache stores fetched feed bodies on disk, keyed by URL, together with
+// the validators needed for conditional requests.
+type feedCache struct {
+	dir string
+}
+
+type cacheMeta struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	FetchedAt    time.Time `json:"fetchedAt"`
+}
+
+// defaultCacheDir is the per-user cache directory, or "" when the platform
+// doesn't define one.
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "ipsw-timeline")
+}
+
+func (c *feedCache) paths(url string) (body, meta string) {
+	base := c.base(url)
+	return base + ".body", base + ".json"
+}
+
+func (c *feedCache) base(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:8]))
+}
+
+// parsedEntry is a feed's normalized items as saved by -cache-parsed. Key
+// is the parsedKey they were made under; any other key means they are out
+// of date.
+type parsedEntry struct {
+	Key   string `json:"key"`
+	Feed  Feed   `json:"feed"`
+	Items []Item `json:"items"`
+}
+
+// parsedVersion changes whenever Item or normalization does, so entries
+// written by other versions are ignored.
+const parsedVersion = 1
+
+// parsedKey identifies what normalizing body with opts produces: the body
+// itself, every option, and the config-file platforms and -locale catalog,
+// which feed into platform keys and labels.
+func parsedKey(body []byte, opts normalizeOptions) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "v%d\n", parsedVersion)
+	h.Write(body)
+	keywords, rewrites := opts.PreReleaseKeywords, opts.TitleRewrites
+	opts.PreReleaseKeywords, opts.TitleRewrites = nil, nil
+	fmt.Fprintf(h, "\n%+v\n", opts)
+	for _, k := range keywords {
+		fmt.Fprintf(h, "%q=%q;", k.word, k.stage)
+	}
+	for _, r := range rewrites {
+		fmt.Fprintf(h, "%q=%q/%t;", r.old, r.new, r.pattern != nil)
+	}
+	fmt.Fprintf(h, "\n%v\n%v\n%v", customPlatforms, customPlatformNames, messages)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// loadParsed returns the saved items for url if they were made under key.
+func (c *feedCache) loadParsed(url, key string) *parsedEntry {
+	raw, err := os.ReadFile(c.base(url) + ".items.json")
+	if err != nil {
+		return nil
+	}
+	var entry parsedEntry
+	if err := json.Unmarshal(raw, &entry); err != nil || entry.Key != key {
+		return nil
+	}
+	return &entry
+}
+
+// storeParsed replaces the saved items for url.
+func (c *feedCache) storeParsed(url string, entry parsedEntry) error {
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return err
+	}
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(c.base(url)+".items.json", raw)
+}
+
+// load returns the cached entry for url. A missing entry is reported as an
+// error satisfying errors.Is(err, os.ErrNotExist).
+func (c *feedCache) load(url string) (*cacheMeta, []byte, error) {
+	bodyPath, metaPath := c.paths(url)
+	raw, err := os.ReadFile(metaPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	var meta cacheMeta
+	if err := json.Unmarshal(raw, &meta); err != nil {
+		return nil, nil, err
+	}
+	body, err := os.ReadFile(bodyPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &meta, body, nil
+}
+
+// store replaces the cached body and metadata for url.
+func (c *feedCache) store(url string, meta cacheMeta, body []byte) error {
+	bodyPath, _ := c.paths(url)
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(bodyPath, body); err != nil {
+		return err
+	}
+	return c.touch(url, meta)
+}
+
+// touch rewrites only the metadata, e.g. to restart the TTL after a 304.
+func (c *feedCache) touch(url string, meta cacheMeta) error {
+	_, metaPath := c.paths(url)
+	meta.URL = url
+	raw, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(metaPath, raw)
+}
+
+// writeFileAtomic writes via a temporary file and a rename so readers never
+// see a partially written file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
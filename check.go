@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// runCheck is the cheap liveness probe behind -check: every feed must be
+// fetched with a 2xx status. Nothing is parsed, rendered, cached or dumped,
+// and nothing is printed on success unless -verbose is given. Failures exit
+// with the fetch or status code so orchestrators can tell them apart.
+func runCheck(cfg Config) {
+	f := newFetcher(cfg)
+	f.cache = nil
+	f.dumpPaths = nil
+
+	feeds := append([]string(nil), cfg.Feeds...)
+	if cfg.ExpectedFeed != "" {
+		feeds = append(feeds, cfg.ExpectedFeed)
+	}
+	for _, feedURL := range feeds {
+		if _, err := f.fetchFeed(context.Background(), feedURL); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitCode(err))
+		}
+		if cfg.Verbose {
+			fmt.Fprintf(os.Stdout, "OK %s\n", feedURL)
+		}
+	}
+}
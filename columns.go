@@ -0,0 +1,525 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// column is one table column. Fixed columns always use width; flex columns
+// share whatever the terminal has left. lead is the part of the width taken
+// by a marker drawn before the cell text (the platform stripe), which the
+// header leaves blank. From layout step shortAt on, a fixed column switches
+// to shortWidth and shortHeader.
+type column struct {
+	key         string
+	header      string
+	width       int
+	flex        bool
+	lead        int
+	shortAt     int
+	shortWidth  int
+	shortHeader string
+	// message is the catalog key of the header; "header."+key when empty.
+	message string
+	cell    func(it Item, width int, opts renderOptions, c colorizer) string
+}
+
+const minFlexWidth = 16
+
+// Layout steps for narrow terminals, tried in order until the table fits.
+// Each step keeps the changes of the ones before it.
+const (
+	layoutFull          = iota
+	layoutNoBuild       // version without the build
+	layoutShortDate     // "01-02 15:04" instead of the full timestamp
+	layoutShortPlatform // three-letter platform codes such as "iPd"
+	layoutNoFlex        // flex columns (device/notes) dropped
+	layoutDropped       // fixed columns dropped in dropOrder until it fits
+)
+
+// dropOrder is the order in which layoutDropped gives up fixed columns,
+// least useful first. The version column is never dropped.
+var dropOrder = []string{"source", "guid", "change", "security", "devices", "build", "date", "platform"}
+
+var defaultFields = []string{"date", "platform", "version", "device"}
+
+var knownColumns = map[string]column{
+	"date": {
+		key:        "date",
+		header:     "Published",
+		width:      20,
+		shortAt:    layoutShortDate,
+		shortWidth: 11,
+		cell: func(it Item, width int, opts renderOptions, c colorizer) string {
+			date := it.DisplayDate
+			switch {
+			case opts.compactDates():
+				date = it.PubDate.UTC().Format("15:04 UTC")
+			case opts.Layout >= layoutShortDate:
+				date = it.PubDate.UTC().Format("01-02 15:04")
+			}
+			return pad(truncate(date, width, opts.Ellipsis), width)
+		},
+	},
+	"platform": {
+		key:         "platform",
+		header:      "Platform",
+		width:       14,
+		lead:        2,
+		shortAt:     layoutShortPlatform,
+		shortWidth:  5,
+		shortHeader: "OS",
+		cell:        platformCell,
+	},
+	"version": {
+		key:         "version",
+		header:      "Version (Build)",
+		width:       24,
+		shortAt:     layoutNoBuild,
+		shortWidth:  12,
+		shortHeader: "Version",
+		cell:        versionCell,
+	},
+	"build": {
+		key:    "build",
+		header: "Build",
+		width:  10,
+		cell: func(it Item, width int, opts renderOptions, c colorizer) string {
+			return pad(truncate(it.Build, width, opts.Ellipsis), width)
+		},
+	},
+	"security": {
+		key:    "security",
+		header: "Sec",
+		width:  3,
+		cell:   securityCell,
+	},
+	"devices": {
+		key:    "devices",
+		header: "Devices",
+		width:  11,
+		cell:   deviceCountCell,
+	},
+	"device": {
+		key:    "device",
+		header: "Device / Notes",
+		flex:   true,
+		cell: func(it Item, width int, opts renderOptions, c colorizer) string {
+			text := it.DeviceOrNotes
+			if opts.EmptyNotes == "placeholder" && !hasNotes(it) {
+				text = opts.NotesPlaceholder
+			}
+			field := opts.fit("device", text, width)
+			if it.Provenance == provenanceExpected {
+				return c.wrap("2;3", field)
+			}
+			return c.dim(field)
+		},
+	},
+	"title": {
+		key:    "title",
+		header: "Title",
+		flex:   true,
+		cell: func(it Item, width int, opts renderOptions, c colorizer) string {
+			return opts.fit("title", normalizeSpace(it.Title), width)
+		},
+	},
+	"link": {
+		key:    "link",
+		header: "Link",
+		flex:   true,
+		cell:   linkCell,
+	},
+	"guid": {
+		key:    "guid",
+		header: "GUID",
+		width:  24,
+		cell: func(it Item, width int, opts renderOptions, c colorizer) string {
+			return c.dim(pad(truncate(itemID(it), width, opts.Ellipsis), width))
+		},
+	},
+	"source": {
+		key:    "source",
+		header: "Source",
+		width:  16,
+		cell: func(it Item, width int, opts renderOptions, c colorizer) string {
+			return c.dim(pad(truncate(it.Source, width, opts.Ellipsis), width))
+		},
+	},
+}
+
+// changeColumn is the +/- gutter in front of the rows of a diff. It isn't
+// selectable with -fields.
+var changeColumn = column{
+	key:   "change",
+	width: 1,
+	cell: func(it Item, width int, opts renderOptions, c colorizer) string {
+		switch it.Change {
+		case "added":
+			return c.wrap("1", "+")
+		case "removed":
+			return c.wrap("1", "-")
+		}
+		return " "
+	},
+}
+
+// changeColor is the color of a diff row: green for additions, red for
+// removals.
+func changeColor(change string) string {
+	switch change {
+	case "added":
+		return "32"
+	case "removed":
+		return "31"
+	}
+	return ""
+}
+
+// wideTerminal is the width from which -columns auto adds the link column.
+const wideTerminal = 160
+
+// autoFields picks the columns for -columns auto: date, platform and version
+// always, the device column when it fits at its minimum width beside them
+// at full layout, and the link column too on terminals at least
+// wideTerminal wide.
+func autoFields(totalWidth, indent, gap int) []string {
+	fields := []string{"date", "platform", "version"}
+	if tableWidth(columnsFor(append(fields, "device")), indent, gap) <= totalWidth {
+		fields = append(fields, "device")
+	}
+	if totalWidth >= wideTerminal {
+		fields = append(fields, "link")
+	}
+	return fields
+}
+
+// addField puts the key column right after the after column, as
+// -split-version-build and -mark-security do, unless the fields (which
+// default to defaultFields) already show it.
+func addField(fields []string, key, after string) []string {
+	if len(fields) == 0 {
+		fields = defaultFields
+	}
+	if slices.Contains(fields, key) {
+		return fields
+	}
+	i := slices.Index(fields, after)
+	if i < 0 {
+		return fields
+	}
+	return slices.Insert(slices.Clone(fields), i+1, key)
+}
+
+// securityCell marks releases with security content: a lock, or "SEC" in
+// ASCII mode. The lock is two columns wide; pad and cut measure it so.
+func securityCell(it Item, width int, opts renderOptions, c colorizer) string {
+	switch {
+	case !it.SecurityContent:
+		return pad("", width)
+	case opts.ASCII:
+		return c.wrap("33", pad(cut("SEC", width), width))
+	}
+	return pad(cut("🔒", width), width)
+}
+
+// deviceCountCell shows how many devices the release is for, such as
+// "12 devices", and nothing when the feed names none.
+func deviceCountCell(it Item, width int, opts renderOptions, c colorizer) string {
+	switch n := len(it.Devices); n {
+	case 0:
+		return pad("", width)
+	case 1:
+		return opts.fit("devices", "1 device", width)
+	default:
+		return opts.fit("devices", fmt.Sprintf("%d devices", n), width)
+	}
+}
+
+// hasNotes reports whether the device/notes cell of it has any text.
+func hasNotes(it Item) bool {
+	return strings.TrimSpace(it.DeviceOrNotes) != ""
+}
+
+// withoutField drops key from fields, which default to defaultFields.
+func withoutField(fields []string, key string) []string {
+	if len(fields) == 0 {
+		fields = defaultFields
+	}
+	return slices.DeleteFunc(slices.Clone(fields), func(f string) bool { return f == key })
+}
+
+func knownColumnKeys() []string {
+	keys := make([]string, 0, len(knownColumns))
+	for k := range knownColumns {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// columnsFor resolves field keys to columns, falling back to the default
+// layout when no fields are given. Unknown keys are skipped; parseFlags has
+// already rejected them.
+func columnsFor(fields []string) []column {
+	if len(fields) == 0 {
+		fields = defaultFields
+	}
+	cols := make([]column, 0, len(fields))
+	for _, f := range fields {
+		if col, ok := knownColumns[strings.ToLower(f)]; ok {
+			cols = append(cols, col)
+		}
+	}
+	return cols
+}
+
+// fitColumns picks the first layout step at which cols fit totalWidth, with
+// flex columns at their minimum width, and returns the columns adjusted for
+// it. When even the last step is too wide, dropColumns gives up whole
+// columns. A table of only flex columns keeps the first one, narrowed to
+// the width there is, rather than dropping them all.
+func fitColumns(cols []column, totalWidth, indent, gap int) ([]column, int) {
+	if len(cols) > 0 && !slices.ContainsFunc(cols, func(col column) bool { return !col.flex }) {
+		if tableWidth(cols, indent, gap) <= totalWidth {
+			return cols, layoutFull
+		}
+		col := cols[0]
+		col.flex = false
+		col.width = max(1, totalWidth-indent)
+		return []column{col}, layoutDropped
+	}
+	for layout := layoutFull; ; layout++ {
+		fitted := make([]column, 0, len(cols))
+		for _, col := range cols {
+			if col.flex && layout >= layoutNoFlex {
+				continue
+			}
+			if col.shortAt > layoutFull && layout >= col.shortAt {
+				col = col.short()
+			}
+			fitted = append(fitted, col)
+		}
+		if tableWidth(fitted, indent, gap) <= totalWidth {
+			return fitted, layout
+		}
+		if layout == layoutNoFlex {
+			return dropColumns(fitted, totalWidth, indent, gap)
+		}
+	}
+}
+
+// dropColumns removes columns in dropOrder until cols fit totalWidth, but
+// never the last one left. A last column that still doesn't fit is
+// narrowed to the space left.
+func dropColumns(cols []column, totalWidth, indent, gap int) ([]column, int) {
+	for _, key := range dropOrder {
+		if tableWidth(cols, indent, gap) <= totalWidth {
+			break
+		}
+		rest := slices.DeleteFunc(slices.Clone(cols), func(col column) bool { return col.key == key })
+		if len(rest) == 0 {
+			break
+		}
+		cols = rest
+	}
+	if len(cols) == 1 && tableWidth(cols, indent, gap) > totalWidth {
+		cols[0].width = max(1, totalWidth-indent-cols[0].lead) + cols[0].lead
+	}
+	return cols, layoutDropped
+}
+
+// fit pads s to width, truncating it unless key is the column allowed to
+// overflow under -no-truncate.
+func (o renderOptions) fit(key, s string, width int) string {
+	if o.Overflow == key {
+		return pad(s, width)
+	}
+	return pad(truncate(s, width, o.Ellipsis), width)
+}
+
+// short returns the column at its short width and header.
+func (col column) short() column {
+	col.width = col.shortWidth
+	if col.shortHeader != "" {
+		col.header = col.shortHeader
+	}
+	return col
+}
+
+// buildFirst returns the version column as -build-first shows it, with
+// the build leading in its header.
+func (col column) buildFirst() column {
+	col.header = "Build (Version)"
+	col.shortHeader = "Build"
+	col.message = "header.version.build-first"
+	return col
+}
+
+// tableWidth is the narrowest a row of cols can be.
+func tableWidth(cols []column, indent, gap int) int {
+	width := indent
+	for i, col := range cols {
+		if i > 0 {
+			width += len(columnGap(col, gap))
+		}
+		if col.flex {
+			width += minFlexWidth
+		} else {
+			width += col.width
+		}
+	}
+	return width
+}
+
+// rowWidth is the width of a row of cols drawn at widths.
+func rowWidth(cols []column, widths []int, indent, gap int) int {
+	width := indent
+	for i, col := range cols {
+		if i > 0 {
+			width += len(columnGap(col, gap))
+		}
+		width += widths[i]
+	}
+	return width
+}
+
+// columnGap is the space written before a column: gap spaces, or one more
+// before a flex column so the free-form text stands apart from the fixed
+// fields.
+func columnGap(col column, gap int) string {
+	if col.flex {
+		gap++
+	}
+	return strings.Repeat(" ", gap)
+}
+
+// columnWidths assigns fixed widths and splits the remaining width evenly
+// across flex columns, never going below minFlexWidth.
+func columnWidths(cols []column, totalWidth, indent, gap int) []int {
+	widths := make([]int, len(cols))
+	used := indent
+	flexCount := 0
+	for i, col := range cols {
+		if i > 0 {
+			used += len(columnGap(col, gap))
+		}
+		if col.flex {
+			flexCount++
+			continue
+		}
+		widths[i] = col.width
+		used += col.width
+	}
+	if flexCount == 0 {
+		return widths
+	}
+
+	flexWidth := (totalWidth - used) / flexCount
+	if flexWidth < minFlexWidth {
+		flexWidth = minFlexWidth
+	}
+	for i, col := range cols {
+		if col.flex {
+			widths[i] = flexWidth
+		}
+	}
+	return widths
+}
+
+func platformCell(it Item, width int, opts renderOptions, c colorizer) string {
+	platformKey := it.PlatformKey
+	if platformKey == "" {
+		platformKey = "other"
+	}
+	stripe := stripeChar(platformKey, opts.ASCII)
+	labelWidth := width - 2
+	label := platformLabelForKey(platformKey)
+	if opts.ShortPlatform || opts.Layout >= layoutShortPlatform {
+		label = platformCodeForKey(platformKey)
+	}
+	field := pad(truncate(label, labelWidth, opts.Ellipsis), labelWidth)
+	if !c.enabled {
+		return stripe + " " + field
+	}
+
+	colorCode := platformColor(platformKey)
+	if it.Provenance == provenanceExpected {
+		return c.color(colorCode, stripe) + " " + c.wrap("2;3;"+colorCode, field)
+	}
+	return c.color(colorCode, stripe) + " " + c.color(colorCode, field)
+}
+
+func versionCell(it Item, width int, opts renderOptions, c colorizer) string {
+	version, build := normalizeVersion(it.Version, opts.NormalizeVersion), it.Build
+	switch {
+	case opts.SplitVersionBuild:
+		build = ""
+	case opts.Layout >= layoutNoBuild && opts.BuildFirst && build != "":
+		version = ""
+	case opts.Layout >= layoutNoBuild:
+		build = ""
+	}
+	versionText := buildVersion(version, build, opts.BuildFirst)
+	expected := it.Provenance == provenanceExpected
+	if expected {
+		versionText = "~" + versionText
+	}
+	recent := isRecent(it, opts)
+	if recent && !c.enabled {
+		versionText = "NEW " + versionText
+	}
+	if it.New {
+		versionText = "*" + versionText
+	}
+	field := pad(truncate(versionText, width, opts.Ellipsis), width)
+	if !c.enabled {
+		return field
+	}
+
+	colorCode := platformColor(it.PlatformKey)
+	if it.PlatformKey == "" {
+		colorCode = platformColor("other")
+	}
+	if expected {
+		return c.wrap("2;3;"+colorCode, field)
+	}
+	if it.New {
+		text := truncate(versionText, width, opts.Ellipsis)
+		return c.wrap("7;"+colorCode, text) + field[len(text):]
+	}
+	if recent {
+		return c.wrap("1;"+colorCode, field)
+	}
+	return colorizeVersion(field, colorCode, it.PreRelease, c)
+}
+
+// linkCell shows the item link, truncated when it doesn't fit.
+// With -hyperlinks the text is wrapped in an OSC 8 hyperlink, so a cut link
+// still opens the full URL.
+func linkCell(it Item, width int, opts renderOptions, c colorizer) string {
+	text := it.Link
+	if opts.Overflow != "link" {
+		text = truncate(text, width, opts.Ellipsis)
+	}
+	padding := strings.Repeat(" ", max(0, width-displayWidth(text)))
+	if opts.Hyperlinks && it.Link != "" {
+		text = hyperlink(it.Link, text)
+	}
+	return c.dim(text) + padding
+}
+
+// hyperlink wraps text in an OSC 8 escape pointing at url.
+func hyperlink(url, text string) string {
+	return "\033]8;;" + url + "\033\\" + text + "\033]8;;\033\\"
+}
+
+// isRecent reports whether a released item falls within -highlight-age.
+func isRecent(it Item, opts renderOptions) bool {
+	if opts.HighlightAge <= 0 || it.Provenance == provenanceExpected {
+		return false
+	}
+	return it.PubDate.After(opts.Now.Add(-opts.HighlightAge))
+}
@@ -0,0 +1,490 @@
+package main
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// checkFits fails when a line of out is wider than width.
+func checkFits(t *testing.T, out string, width int) {
+	t.Helper()
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+		if w := displayWidth(line); w > width {
+			t.Errorf("line is %d wide, more than %d: %q", w, width, line)
+		}
+	}
+}
+
+func TestRenderTableWidth30(t *testing.T) {
+	out := renderTableString(loadFixture(t, "timeline.rss"), plainOptions(30))
+	checkFits(t, out, 30)
+	checkGolden(t, "table-30", out)
+}
+
+func TestRenderTableResponsive(t *testing.T) {
+	items := loadFixture(t, "timeline.rss")
+	for _, width := range []int{40, 60, 80} {
+		out := renderTableString(items, plainOptions(width))
+		checkFits(t, out, width)
+		checkGolden(t, "table-"+strconv.Itoa(width), out)
+	}
+}
+
+func TestCompactDates(t *testing.T) {
+	items := loadFixture(t, "timeline.rss")
+	opts := plainOptions(80)
+	opts.CompactDates = true
+	out := renderTableString(items, opts)
+	checkGolden(t, "table-compact-dates", out)
+
+	opts.GroupBy = "platform"
+	if out := renderTableString(items, opts); !strings.Contains(out, "2023-11-07 18:00 UTC") {
+		t.Errorf("without day dividers the full date should stay:\n%s", out)
+	}
+}
+
+func TestWidthsCountCharactersNotBytes(t *testing.T) {
+	t.Cleanup(func() { messages = nil })
+	if err := selectLocale("de", nil); err != nil {
+		t.Fatal(err)
+	}
+	opts := plainOptions(80)
+	opts.Color = true
+	out := renderTableString(loadFixture(t, "timeline.rss"), opts)
+	checkFits(t, out, 80)
+
+	lines := strings.Split(out, "\n")
+	header, underline := lines[0], lines[1]
+	if !strings.Contains(header, "Veröffentlicht") || !strings.Contains(header, "Gerät") {
+		t.Fatalf("header %q isn't German", header)
+	}
+	if len(header) == displayWidth(header) {
+		t.Fatalf("header %q has no multibyte text to measure", header)
+	}
+	if displayWidth(underline) != displayWidth(header) {
+		t.Errorf("underline is %d wide, header %d", displayWidth(underline), displayWidth(header))
+	}
+	for _, line := range lines[2:] {
+		if strings.HasPrefix(line, " 2023-") && displayWidth(line) != 80 {
+			t.Errorf("divider is %d wide, want 80: %q", displayWidth(line), line)
+		}
+	}
+}
+
+func TestNoTruncate(t *testing.T) {
+	items := loadFixture(t, "timeline.rss")
+	opts := plainOptions(60)
+	opts.NoTruncate = true
+	out := renderTableString(items, opts)
+	for _, it := range items {
+		if !strings.Contains(out, it.DeviceOrNotes) {
+			t.Errorf("%q not emitted in full:\n%s", it.DeviceOrNotes, out)
+		}
+	}
+	if strings.Contains(out, "…") {
+		t.Errorf("-no-truncate output still cut something:\n%s", out)
+	}
+
+	opts.Fields = []string{"device", "version"}
+	out = renderTableString(items, opts)
+	checkFits(t, out, 60)
+	if !strings.Contains(out, "…") {
+		t.Errorf("device not last: it should still be cut:\n%s", out)
+	}
+}
+
+func TestColumnsAuto(t *testing.T) {
+	items := loadFixture(t, "timeline.rss")
+	for _, width := range []int{60, 100, 160} {
+		opts := plainOptions(width)
+		opts.Columns = "auto"
+		out := renderTableString(items, opts)
+		checkFits(t, out, width)
+		checkGolden(t, "columns-auto-"+strconv.Itoa(width), out)
+	}
+
+	for width, want := range map[int]string{
+		60:  "date platform version",
+		100: "date platform version device",
+		159: "date platform version device",
+		160: "date platform version device link",
+	} {
+		if got := strings.Join(autoFields(width, 2, 1), " "); got != want {
+			t.Errorf("autoFields(%d) = %s, want %s", width, got, want)
+		}
+	}
+}
+
+func TestGroupHeaderStyles(t *testing.T) {
+	items := loadFixture(t, "timeline.rss")[:2]
+	for _, style := range []string{"dashes", "rule", "header", "bold"} {
+		opts := plainOptions(60)
+		opts.Divider = style
+		opts.Color = true
+		out := renderTableString(items, opts)
+		checkFits(t, out, 60)
+		checkGolden(t, "divider-"+style, strings.ReplaceAll(out, "\033", `\e`))
+	}
+
+	color := colorizer{enabled: true}
+	opts := plainOptions(30)
+	for _, tt := range []struct{ style, want string }{
+		// The current look: the label, then dashes to the edge.
+		{"dashes", " 2023-11-07 ------------------"},
+		// A full-width rule with the label in the middle.
+		{"rule", "───────── 2023-11-07 ─────────"},
+		// No fill: the label alone, bold in its group's platform color.
+		{"bold", " \033[1;31m2023-11-07\033[0m"},
+		{"header", " \033[1;4m2023-11-07\033[0m"},
+	} {
+		opts.Divider = tt.style
+		if got := dayDivider("2023-11-07", "31", 30, opts, color); got != tt.want {
+			t.Errorf("%s: %q, want %q", tt.style, got, tt.want)
+		}
+	}
+	opts.Divider, opts.ASCII = "rule", true
+	if got, want := dayDivider("2023-11-07", "31", 30, opts, color), "--------- 2023-11-07 ---------"; got != want {
+		t.Errorf("ASCII rule: %q, want %q", got, want)
+	}
+}
+
+func TestFitColumnsKeepsOnlyFlexColumn(t *testing.T) {
+	items := loadFixture(t, "timeline.rss")
+	for _, field := range []string{"title", "device"} {
+		opts := plainOptions(10)
+		opts.Fields = []string{field}
+		out := renderTableString(items, opts)
+		checkFits(t, out, 10)
+		lines := strings.Split(out, "\n")
+		if strings.TrimSpace(lines[0]) == "" {
+			t.Errorf("-fields %s: empty header in\n%s", field, out)
+		}
+		if row := lines[3]; !strings.HasSuffix(row, "…") {
+			t.Errorf("-fields %s: row %q isn't cut with the ellipsis", field, row)
+		}
+	}
+
+	cols, _ := fitColumns(columnsFor([]string{"title", "device"}), 10, 2, 1)
+	if len(cols) != 1 || cols[0].key != "title" || cols[0].width != 8 {
+		t.Errorf("fitColumns(title, device) at 10 = %+v, want title 8 wide", cols)
+	}
+}
+
+func TestDropColumnsKeepsLastColumn(t *testing.T) {
+	items := loadFixture(t, "timeline.rss")
+	for _, tt := range []struct {
+		width  int
+		fields []string
+		keep   string
+	}{
+		{5, []string{"guid", "source"}, "guid"},
+		{3, []string{"date", "platform"}, "platform"},
+		{8, []string{"security", "date"}, "date"},
+		// Every column of dropOrder that -fields can name, at a width
+		// none of them fits.
+		{1, []string{"source", "guid", "security", "devices", "build", "date", "platform"}, "platform"},
+	} {
+		cols, layout := fitColumns(columnsFor(tt.fields), tt.width, defaultIndent, defaultGap)
+		if len(cols) != 1 || cols[0].key != tt.keep || layout != layoutDropped {
+			t.Errorf("-fields %s at %d: kept %d columns, want just %s", strings.Join(tt.fields, ","), tt.width, len(cols), tt.keep)
+		}
+		opts := plainOptions(tt.width)
+		opts.Fields = tt.fields
+		if out := renderTableString(items, opts); strings.Count(out, "\n") != 10 {
+			t.Errorf("-fields %s at %d: want the header, dividers and every row:\n%s", strings.Join(tt.fields, ","), tt.width, out)
+		}
+	}
+}
+
+func TestPadToWidth(t *testing.T) {
+	opts := plainOptions(80)
+	opts.PadToWidth = true
+	opts.Legend = true
+	opts.Color = true
+	out := renderTableString(loadFixture(t, "timeline.rss"), opts)
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+		if w := displayWidth(line); w != 80 {
+			t.Errorf("line is %d wide, want 80: %q", w, line)
+		}
+	}
+	checkGolden(t, "table-pad-legend", stripANSI(out))
+}
+
+func TestHighlightAgeBoundary(t *testing.T) {
+	opts := plainOptions(80)
+	opts.HighlightAge = 24 * time.Hour
+	at := func(age time.Duration) Item {
+		return newItem(t, "iOS 17.1.1 (21B91) has been released", testNow.Add(-age).Format(time.RFC1123Z))
+	}
+
+	tests := []struct {
+		age  time.Duration
+		want bool
+	}{
+		{time.Hour, true},
+		{24*time.Hour - time.Second, true},
+		{24 * time.Hour, false},
+		{24*time.Hour + time.Second, false},
+	}
+	for _, tt := range tests {
+		it := at(tt.age)
+		if got := isRecent(it, opts); got != tt.want {
+			t.Errorf("age %v: isRecent = %t, want %t", tt.age, got, tt.want)
+		}
+
+		plain := versionCell(it, 20, opts, colorizer{})
+		if got := strings.HasPrefix(plain, "NEW "); got != tt.want {
+			t.Errorf("age %v, no color: %q, want NEW prefix %t", tt.age, plain, tt.want)
+		}
+		colored := versionCell(it, 20, opts, colorizer{enabled: true})
+		if strings.Contains(colored, "NEW") {
+			t.Errorf("age %v, color: %q has the textual NEW badge", tt.age, colored)
+		}
+		if got := strings.HasPrefix(colored, "\033[1;31m"); got != tt.want {
+			t.Errorf("age %v, color: %q, want bold iOS red %t", tt.age, colored, tt.want)
+		}
+	}
+
+	opts.HighlightAge = 0
+	if isRecent(at(time.Minute), opts) {
+		t.Error("-highlight-age 0 still highlights")
+	}
+}
+
+func TestRawTitleColumn(t *testing.T) {
+	if !slices.Contains(knownColumnKeys(), "title") {
+		t.Fatalf("known columns %v lack title", knownColumnKeys())
+	}
+
+	const title = "iOS 17.1.1 (21B91) for iPhone 15, iPhone 15 Pro has been released"
+	norm := testNormalizeOptions(t)
+	norm.MaxTitleLen = 20
+	it := normalizeItem(rawItem{Title: title, PubDate: "Tue, 07 Nov 2023 18:00:00 +0000", GUID: "ios-21B91"}, norm)
+	if it.Title != title {
+		t.Errorf("Title = %q, want the original %q", it.Title, title)
+	}
+	if got := toJSONItem(it).Title; got != title {
+		t.Errorf("JSON title = %q, want it in full", got)
+	}
+
+	for _, width := range []int{80, 200} {
+		opts := plainOptions(width)
+		opts.Fields = append(slices.Clone(defaultFields), "title")
+		out := renderTableString([]Item{it}, opts)
+		checkFits(t, out, width)
+		lines := strings.Split(out, "\n")
+		if !strings.HasSuffix(strings.TrimRight(lines[0], " "), "Title") {
+			t.Errorf("width %d: header %q doesn't end in Title", width, lines[0])
+		}
+		row := strings.TrimRight(lines[3], " ")
+		switch {
+		case width == 200 && !strings.HasSuffix(row, title):
+			t.Errorf("width %d: row %q doesn't end in the full title", width, row)
+		case width == 80 && (!strings.HasSuffix(row, "…") || !strings.Contains(row, "iOS 17.1")):
+			t.Errorf("width %d: row %q doesn't end in the cut title", width, row)
+		}
+	}
+}
+
+func TestSplitVersionBuild(t *testing.T) {
+	for _, tt := range []struct{ version, build, want string }{
+		{"17.1", "21B74", "17.1 (21B74)"},
+		{"17.1", "", "17.1"},
+		{"", "21B74", "21B74"},
+		{" ", " ", ""},
+	} {
+		if got := buildVersion(tt.version, tt.build, false); got != tt.want {
+			t.Errorf("buildVersion(%q, %q, false) = %q, want %q", tt.version, tt.build, got, tt.want)
+		}
+	}
+
+	const date = "Tue, 07 Nov 2023 18:00:00 +0000"
+	both := newItem(t, "iOS 17.1 (21B74) has been released", date)
+	versionOnly := newItem(t, "iPadOS 17.1 has been released", date)
+	buildOnly := newItem(t, "tvOS 17.1 (21K69) has been released", date)
+	buildOnly.Version = ""
+	items := []Item{both, versionOnly, buildOnly}
+
+	opts := plainOptions(80)
+	opts.SplitVersionBuild = true
+	out := renderTableString(items, opts)
+	checkFits(t, out, 80)
+	checkGolden(t, "table-split-version-build", out)
+}
+
+func TestEmptyNotesHide(t *testing.T) {
+	const date = "Tue, 07 Nov 2023 18:00:00 +0000"
+	bare := []Item{
+		newItem(t, "macOS 14.2 beta 2 (23C5041e) has been released", date),
+		newItem(t, "tvOS 17.1 (21K69) has been released", date),
+	}
+	opts := plainOptions(80)
+	opts.EmptyNotes = "hide"
+	if out := renderTableString(bare, opts); strings.Contains(out, "Device / Notes") {
+		t.Errorf("hide kept the device column with every row empty:\n%s", out)
+	}
+
+	mixed := append(bare, newItem(t, "iOS 17.1.1 (21B91) for iPhone 15 has been released", date))
+	if out := renderTableString(mixed, opts); !strings.Contains(out, "Device / Notes") || !strings.Contains(out, "iPhone 15") {
+		t.Errorf("hide dropped the device column with one row filled:\n%s", out)
+	}
+
+	opts.EmptyNotes = "placeholder"
+	if out := renderTableString(bare, opts); strings.Count(out, "—") != len(bare) {
+		t.Errorf("placeholder: want one — per empty row:\n%s", out)
+	}
+}
+
+func TestFitHeight(t *testing.T) {
+	items := loadFixture(t, "timeline.rss")
+	// The fixture spans three days, so all 5 rows take 2 header lines, 3
+	// dividers and 5 rows, plus one for the prompt.
+	for _, tt := range []struct {
+		lines  string
+		legend bool
+		rows   int
+	}{
+		{"24", false, 5},
+		{"11", false, 5},
+		{"10", false, 4},
+		{"8", false, 3},
+		{"13", true, 5},
+		{"12", true, 4},
+		{"5", false, 1},
+		{"4", false, 0},
+		{"", false, 5},
+		{"junk", false, 5},
+	} {
+		t.Setenv("LINES", tt.lines)
+		opts := plainOptions(80)
+		opts.Legend = tt.legend
+		opts.MaxLines = terminalHeight()
+		if got := rowsThatFit(items, opts, opts.MaxLines); got != tt.rows {
+			t.Errorf("LINES=%q legend %t: %d rows fit, want %d", tt.lines, tt.legend, got, tt.rows)
+		}
+		out := renderTableString(items, opts)
+		if n := strings.Count(out, "\n"); n > opts.MaxLines-1 {
+			t.Errorf("LINES=%q legend %t: table is %d lines, want at most %d to leave the prompt a line:\n%s",
+				tt.lines, tt.legend, n, opts.MaxLines-1, out)
+		}
+		if n := strings.Count(out, " UTC "); n != tt.rows {
+			t.Errorf("LINES=%q legend %t: drew %d rows, want %d", tt.lines, tt.legend, n, tt.rows)
+		}
+	}
+	if n := fitHeightLines(Config{FitHeight: true, Output: "timeline.txt"}); n != 0 {
+		t.Errorf("-fit-height with -output: %d lines, want no cap", n)
+	}
+}
+
+func TestBuildFirst(t *testing.T) {
+	for _, tt := range []struct{ version, build, want string }{
+		{"17.1", "21B74", "21B74 (17.1)"},
+		{"17.1", "", "17.1"},
+		{"", "21B74", "21B74"},
+	} {
+		if got := buildVersion(tt.version, tt.build, true); got != tt.want {
+			t.Errorf("buildVersion(%q, %q, true) = %q, want %q", tt.version, tt.build, got, tt.want)
+		}
+	}
+
+	const date = "Tue, 07 Nov 2023 18:00:00 +0000"
+	items := []Item{
+		newItem(t, "iOS 17.1 (21B74) has been released", date),
+		newItem(t, "iPadOS 17.1 has been released", date),
+		newItem(t, "tvOS 17.1 (21K69) has been released", date),
+	}
+	items[2].Version = ""
+	opts := plainOptions(80)
+	opts.BuildFirst = true
+	wide := renderTableString(items, opts)
+	for _, want := range []string{"Build (Version)", "21B74 (17.1)", "iPadOS       17.1 ", "tvOS         21K69 "} {
+		if !strings.Contains(wide, want) {
+			t.Errorf("80 columns: no %q in\n%s", want, wide)
+		}
+	}
+	// Too narrow for both, the build is what stays.
+	opts.Width = 40
+	narrow := renderTableString(items, opts)
+	checkFits(t, narrow, 40)
+	if !strings.Contains(narrow, "21B74") || strings.Contains(narrow, "(17.1)") {
+		t.Errorf("40 columns: want the build without the version:\n%s", narrow)
+	}
+
+	// The other outputs take it from their options too.
+	var page, badge strings.Builder
+	if err := renderHTML(items[:1], true, &page); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(page.String(), "Build (Version)") || !strings.Contains(page.String(), "21B74 (17.1)") {
+		t.Errorf("HTML: want the build first:\n%s", page.String())
+	}
+	if err := renderBadge(items[:1], true, true, &badge); err != nil || badge.String() != "iOS 21B74 (17.1)\n" {
+		t.Errorf("badge = %q, %v; want %q", badge.String(), err, "iOS 21B74 (17.1)\n")
+	}
+	nopts := testNormalizeOptions(t)
+	nopts.BuildFirst = true
+	it := normalizeItem(rawItem{Title: "iOS 17.1 (21B74) has been released", PubDate: date}, nopts)
+	if it.DisplayVersion != "21B74 (17.1)" {
+		t.Errorf("DisplayVersion = %q, want %q", it.DisplayVersion, "21B74 (17.1)")
+	}
+	if parsedKey(nil, nopts) == parsedKey(nil, testNormalizeOptions(t)) {
+		t.Error("parsedKey ignores BuildFirst")
+	}
+}
+
+func TestRenderTableString(t *testing.T) {
+	items := loadFixture(t, "timeline.rss")
+	opts := plainOptions(76)
+	opts.Color = true
+	opts.Fields = []string{"date", "platform", "version", "link"}
+	opts.Legend = true
+	got := renderTableString(items, opts)
+
+	var b strings.Builder
+	renderTable(items, opts, &b)
+	if got != b.String() {
+		t.Errorf("renderTableString differs from what renderTable writes:\n%s\nwant\n%s", got, b.String())
+	}
+	checkFits(t, got, 76)
+	checkGolden(t, "table-string-color", strings.ReplaceAll(got, "\033", `\e`))
+}
+
+func TestMarkSecurityAlignment(t *testing.T) {
+	items := loadFixture(t, "timeline.rss")
+	for _, ascii := range []bool{false, true} {
+		opts := plainOptions(72)
+		opts.ASCII = ascii
+		opts.MarkSecurity = true
+		opts.PadToWidth = true
+		out := renderTableString(items, opts)
+		marked, col := 0, -1
+		for _, line := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+			if w := displayWidth(line); w != 72 {
+				t.Errorf("ascii %t: line is %d wide, want 72: %q", ascii, w, line)
+			}
+			i := strings.Index(line, "Apple Watch")
+			if i < 0 {
+				i = strings.Index(line, "iPad Pro")
+			}
+			if i < 0 {
+				continue
+			}
+			// The device after the marker starts in the same column on a
+			// marked row as on an unmarked one.
+			at := displayWidth(line[:i])
+			if col >= 0 && at != col {
+				t.Errorf("ascii %t: device at column %d, want %d:\n%s", ascii, at, col, out)
+			}
+			col = at
+			if strings.Contains(line, "🔒") || strings.Contains(line, "SEC") {
+				marked++
+			}
+		}
+		if marked != 1 {
+			t.Errorf("ascii %t: %d marked rows, want 1:\n%s", ascii, marked, out)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// fileConfig is the JSON config file read from -config.
+type fileConfig struct {
+	Platforms      []platformMapping `json:"platforms,omitempty"`
+	PlatformLimits map[string]int    `json:"platformLimits,omitempty"`
+	// Messages adds or overrides -locale translations, by locale and then
+	// message key, such as "de": {"header.date": "Datum"}.
+	Messages map[string]map[string]string `json:"messages,omitempty"`
+	// FeedAliases names feed URLs, so "-feed-url @staging" can stand for
+	// the URL under "staging".
+	FeedAliases map[string]string `json:"feedAliases,omitempty"`
+	// Flags holds command-line flags by name, such as "timeout": 10 or
+	// "feed-url": ["https://..."]. Flags given on the command line win.
+	Flags map[string]any `json:"flags,omitempty"`
+}
+
+// platformMapping adds a platform or overrides a built-in one. Match lists
+// further names that may appear in feed titles besides the key and label;
+// like the built-in names they are compared ignoring case and spaces. Color
+// is an SGR code such as "33".
+type platformMapping struct {
+	Key   string   `json:"key"`
+	Label string   `json:"label,omitempty"`
+	Color string   `json:"color,omitempty"`
+	Match []string `json:"match,omitempty"`
+}
+
+// resolveFeedAlias returns the URL an "@name" feed stands for: the config
+// file's alias of that name, or for "@default" the ipsw.me timeline. Other
+// feeds are returned as they are.
+func resolveFeedAlias(feed string, aliases map[string]string) (string, error) {
+	name, ok := strings.CutPrefix(feed, "@")
+	if !ok {
+		return feed, nil
+	}
+	if url, ok := aliases[name]; ok {
+		return strings.TrimSpace(url), nil
+	}
+	if name == "default" {
+		return defaultFeedURL, nil
+	}
+	return "", fmt.Errorf("unknown feed alias %q: define it under feedAliases in the config file", feed)
+}
+
+// defaultConfigPath is the per-user config file, or "" when the platform
+// doesn't define a config directory.
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "ipsw-timeline", "config.json")
+}
+
+// loadConfigFile reads path. A missing file yields an empty config unless
+// it was named explicitly with -config.
+func loadConfigFile(path string, explicit bool) (fileConfig, error) {
+	var fc fileConfig
+	if path == "" {
+		return fc, nil
+	}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !explicit {
+		return fc, nil
+	}
+	if err != nil {
+		return fc, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&fc); err != nil {
+		return fc, fmt.Errorf("%s: %w", path, err)
+	}
+	return fc, nil
+}
+
+// unconfigurableFlags pick what a run does rather than how, so they can't
+// come from a config file and aren't dumped.
+var unconfigurableFlags = map[string]bool{
+	"config":       true,
+	"dump-config":  true,
+	"check":        true,
+	"print-schema": true,
+	"strip-ansi":   true,
+	"audit":        true,
+}
+
+// applyConfigFlags sets the flags from the config file that weren't given on
+// the command line. Flags of other commands are skipped, so one file can
+// serve list and watch alike.
+func applyConfigFlags(fs *flag.FlagSet, values map[string]any) error {
+	given := make(map[flag.Value]bool)
+	fs.Visit(func(f *flag.Flag) { given[f.Value] = true })
+	known := allFlagNames()
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if unconfigurableFlags[name] {
+			return fmt.Errorf("flags: -%s cannot be set in a config file", name)
+		}
+		f := fs.Lookup(name)
+		if f == nil {
+			if !known[name] {
+				return fmt.Errorf("flags: unknown flag -%s", name)
+			}
+			continue
+		}
+		if given[f.Value] {
+			continue
+		}
+		list, err := flagStrings(values[name])
+		if err != nil {
+			return fmt.Errorf("flags: -%s: %w", name, err)
+		}
+		for _, s := range list {
+			if err := f.Value.Set(s); err != nil {
+				return fmt.Errorf("flags: -%s: %w", name, err)
+			}
+		}
+		given[f.Value] = true
+	}
+	return nil
+}
+
+// flagStrings turns a JSON flag value into the strings to Set: one for a
+// scalar, one per element for the list of a repeatable flag.
+func flagStrings(value any) ([]string, error) {
+	switch v := value.(type) {
+	case string:
+		return []string{v}, nil
+	case bool:
+		return []string{strconv.FormatBool(v)}, nil
+	case float64:
+		if v != math.Trunc(v) {
+			return nil, fmt.Errorf("%v is not a whole number", v)
+		}
+		return []string{strconv.FormatFloat(v, 'f', -1, 64)}, nil
+	case []any:
+		list := make([]string, 0, len(v))
+		for _, elem := range v {
+			s, ok := elem.(string)
+			if !ok {
+				return nil, fmt.Errorf("list values must be strings")
+			}
+			list = append(list, s)
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("unsupported value %v", value)
+}
+
+// allFlagNames is every flag of every command.
+func allFlagNames() map[string]bool {
+	names := make(map[string]bool)
+	for _, c := range commands {
+		fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+		v := defaultFlagValues()
+		addSharedFlags(fs, &v)
+		addCommandFlags(fs, c.name, &v)
+		fs.VisitAll(func(f *flag.Flag) { names[f.Name] = true })
+	}
+	return names
+}
+
+// dumpConfig is the config file equivalent of the current run: the file's
+// platforms and limits plus the effective value of every flag, under its
+// canonical name when it has aliases. Loading it reproduces the same run.
+func dumpConfig(fs *flag.FlagSet, fc fileConfig) fileConfig {
+	byValue := make(map[flag.Value]*flag.Flag)
+	var order []flag.Value
+	fs.VisitAll(func(f *flag.Flag) {
+		if unconfigurableFlags[f.Name] {
+			return
+		}
+		prev, ok := byValue[f.Value]
+		if !ok {
+			order = append(order, f.Value)
+		}
+		if !ok || isAlias(prev) {
+			byValue[f.Value] = f
+		}
+	})
+
+	fc.Flags = make(map[string]any, len(order))
+	for _, value := range order {
+		f := byValue[value]
+		switch v := f.Value.(type) {
+		case *stringList:
+			fc.Flags[f.Name] = append([]string{}, v.values...)
+		case flag.Getter:
+			switch got := v.Get().(type) {
+			case bool, int, int64, string:
+				fc.Flags[f.Name] = got
+			default:
+				fc.Flags[f.Name] = v.String()
+			}
+		default:
+			fc.Flags[f.Name] = v.String()
+		}
+	}
+	return fc
+}
+
+// isAlias reports whether f was registered as another name for a flag,
+// either a shorthand or a spelled-out alias.
+func isAlias(f *flag.Flag) bool {
+	return strings.HasSuffix(f.Usage, "(shorthand)") || strings.HasPrefix(f.Usage, "Alias for -")
+}
+
+// writeConfig writes fc in the format loadConfigFile reads.
+func writeConfig(out io.Writer, fc fileConfig) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(fc)
+}
+
+// customPlatform holds the config-file overrides for one platform key.
+type customPlatform struct {
+	label string
+	color string
+}
+
+// Platform mappings from the config file, merged over the built-ins by
+// platformKeyForTitle, platformLabelForKey and platformColor. They are set
+// once by parseFlags before any feed is loaded.
+var (
+	customPlatformNames = map[string]string{}
+	customPlatforms     = map[string]customPlatform{}
+)
+
+func platformName(s string) string {
+	return strings.ReplaceAll(strings.ToLower(s), " ", "")
+}
+
+// registerPlatforms validates the config-file mappings and installs them.
+func registerPlatforms(mappings []platformMapping) error {
+	for i, m := range mappings {
+		key := strings.ToLower(strings.TrimSpace(m.Key))
+		if key == "" {
+			return fmt.Errorf("platforms[%d]: key is required", i)
+		}
+		for _, r := range m.Color {
+			if (r < '0' || r > '9') && r != ';' {
+				return fmt.Errorf("platforms[%d]: color %q is not an SGR code like \"33\" or \"1;34\"", i, m.Color)
+			}
+		}
+		p := customPlatforms[key]
+		if m.Label != "" {
+			p.label = m.Label
+		}
+		if m.Color != "" {
+			p.color = m.Color
+		}
+		customPlatforms[key] = p
+		names := append([]string{key, m.Label}, m.Match...)
+		for _, name := range names {
+			if name = platformName(name); name != "" {
+				customPlatformNames[name] = key
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// listFlagSet is the flag set of the list command with default values.
+func listFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	v := defaultFlagValues()
+	addSharedFlags(fs, &v)
+	addCommandFlags(fs, "list", &v)
+	return fs
+}
+
+func TestDumpConfigRoundTrip(t *testing.T) {
+	fs := listFlagSet()
+	args := []string{"-group-header-style", "rule", "-l", "5", "-feed-url", "https://example.com/a.rss", "-f", "b.rss", "-t", "3"}
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	dumped := dumpConfig(fs, fileConfig{})
+	if got := dumped.Flags["divider"]; got != "rule" {
+		t.Errorf("divider = %v, want rule", got)
+	}
+	for _, alias := range []string{"group-header-style", "l", "f", "t"} {
+		if _, ok := dumped.Flags[alias]; ok {
+			t.Errorf("dumped under the alias -%s", alias)
+		}
+	}
+	if got := dumped.Flags["limit"]; got != 5 {
+		t.Errorf("limit = %v, want 5", got)
+	}
+
+	var first bytes.Buffer
+	if err := writeConfig(&first, dumped); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, first.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fc, err := loadConfigFile(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloaded := listFlagSet()
+	if err := reloaded.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyConfigFlags(reloaded, fc.Flags); err != nil {
+		t.Fatal(err)
+	}
+
+	var second bytes.Buffer
+	if err := writeConfig(&second, dumpConfig(reloaded, fc)); err != nil {
+		t.Fatal(err)
+	}
+	if first.String() != second.String() {
+		t.Errorf("reloaded dump differs:\n%s\nwant:\n%s", second.String(), first.String())
+	}
+}
+
+// resetPlatforms drops the config-file platforms when the test ends.
+func resetPlatforms(t *testing.T) {
+	t.Helper()
+	t.Cleanup(func() {
+		customPlatforms = map[string]customPlatform{}
+		customPlatformNames = map[string]string{}
+	})
+}
+
+func TestRegisterPlatforms(t *testing.T) {
+	resetPlatforms(t)
+	err := registerPlatforms([]platformMapping{
+		{Key: "macos", Label: "Mac OS X", Color: "1;32"},
+		{Key: "bridgeos", Label: "bridgeOS", Color: "33", Match: []string{"Bridge"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := platformLabelForKey("macos"); got != "Mac OS X" {
+		t.Errorf("overridden label = %q, want Mac OS X", got)
+	}
+	if got := platformColor("macos"); got != "1;32" {
+		t.Errorf("overridden color = %q, want 1;32", got)
+	}
+	if key, ok := platformKeyForTitle("macOS"); key != "macos" || !ok {
+		t.Errorf("built-in name after override = %q, %t", key, ok)
+	}
+
+	for _, name := range []string{"bridgeOS", "bridgeos", "Bridge"} {
+		if key, ok := platformKeyForTitle(name); key != "bridgeos" || !ok {
+			t.Errorf("platformKeyForTitle(%q) = %q, %t; want bridgeos", name, key, ok)
+		}
+	}
+	it := newItem(t, "bridgeOS 8.1 (21P1069) has been released", "Tue, 07 Nov 2023 18:00:00 +0000")
+	if it.PlatformKey != "bridgeos" || it.PlatformLabel != "bridgeOS" {
+		t.Errorf("added platform item: key %q, label %q", it.PlatformKey, it.PlatformLabel)
+	}
+	if got := platformColor("bridgeos"); got != "33" {
+		t.Errorf("added color = %q, want 33", got)
+	}
+
+	if err := registerPlatforms([]platformMapping{{Key: "x", Color: "red"}}); err == nil {
+		t.Error("non-SGR color: no error")
+	}
+	if err := registerPlatforms([]platformMapping{{Label: "No key"}}); err == nil {
+		t.Error("missing key: no error")
+	}
+}
+
+func TestResolveFeedAlias(t *testing.T) {
+	aliases := map[string]string{"staging": " https://staging.example.com/timeline.rss "}
+	tests := []struct {
+		feed, want string
+		aliases    map[string]string
+	}{
+		{"@staging", "https://staging.example.com/timeline.rss", aliases},
+		{"@default", defaultFeedURL, aliases},
+		{"@default", "file:///srv/mirror.rss", map[string]string{"default": "file:///srv/mirror.rss"}},
+		{"https://example.com/a.rss", "https://example.com/a.rss", aliases},
+		{"feed@host.rss", "feed@host.rss", nil},
+	}
+	for _, tt := range tests {
+		if got, err := resolveFeedAlias(tt.feed, tt.aliases); err != nil || got != tt.want {
+			t.Errorf("resolveFeedAlias(%q, %v) = %q, %v; want %q", tt.feed, tt.aliases, got, err, tt.want)
+		}
+	}
+	if _, err := resolveFeedAlias("@prod", aliases); err == nil {
+		t.Error("unknown alias @prod: no error")
+	}
+
+	// From the command line, through a config file.
+	fixture, err := filepath.Abs(filepath.Join("testdata", "timeline.rss"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"feedAliases": {"local": "file://`+fixture+`"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if out := runListArgs(t, "-config", path, "-f", "@local"); !strings.Contains(out, "17.1.1 (21B91)") {
+		t.Errorf("-f @local did not load the fixture:\n%s", out)
+	}
+}
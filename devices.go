@@ -0,0 +1,99 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// splitDevices breaks a device field such as "iPhone 15, iPhone 15 Plus and
+// iPhone 15 Pro" into its device names. A field naming one device yields a
+// single entry and an empty field none.
+func splitDevices(device string) []string {
+	devices := []string{}
+	for _, part := range strings.Split(device, ",") {
+		for _, name := range splitWords(part, " and ", " & ") {
+			if name = strings.TrimSpace(name); name != "" {
+				devices = append(devices, name)
+			}
+		}
+	}
+	return devices
+}
+
+// splitWords splits s at every occurrence of any of seps.
+func splitWords(s string, seps ...string) []string {
+	parts := []string{s}
+	for _, sep := range seps {
+		var next []string
+		for _, p := range parts {
+			next = append(next, strings.Split(p, sep)...)
+		}
+		parts = next
+	}
+	return parts
+}
+
+// deviceFamilies orders device families for -sort-devices by name prefix;
+// anything else sorts after them.
+var deviceFamilies = []struct {
+	prefix string
+	rank   int
+}{
+	{"iphone", 0}, {"ipad", 1}, {"ipod", 2}, {"mac", 3}, {"imac", 3},
+	{"apple watch", 4}, {"apple tv", 5}, {"apple vision", 6},
+}
+
+func deviceFamily(name string) int {
+	lower := strings.ToLower(name)
+	for _, f := range deviceFamilies {
+		if strings.HasPrefix(lower, f.prefix) {
+			return f.rank
+		}
+	}
+	return len(deviceFamilies)
+}
+
+// sortDevices orders names by family (iPhone, iPad, iPod, Mac, Watch, TV,
+// Vision), then naturally, so "iPhone 9" comes before "iPhone 11".
+func sortDevices(names []string) {
+	slices.SortStableFunc(names, func(a, b string) int {
+		if fa, fb := deviceFamily(a), deviceFamily(b); fa != fb {
+			return fa - fb
+		}
+		return naturalCompare(a, b)
+	})
+}
+
+// naturalCompare compares a and b ignoring case, treating runs of digits as
+// numbers.
+func naturalCompare(a, b string) int {
+	ra, rb := []rune(strings.ToLower(a)), []rune(strings.ToLower(b))
+	i, j := 0, 0
+	for i < len(ra) && j < len(rb) {
+		if unicode.IsDigit(ra[i]) && unicode.IsDigit(rb[j]) {
+			si, sj := i, j
+			for i < len(ra) && unicode.IsDigit(ra[i]) {
+				i++
+			}
+			for j < len(rb) && unicode.IsDigit(rb[j]) {
+				j++
+			}
+			na := strings.TrimLeft(string(ra[si:i]), "0")
+			nb := strings.TrimLeft(string(rb[sj:j]), "0")
+			if len(na) != len(nb) {
+				return len(na) - len(nb)
+			}
+			if c := strings.Compare(na, nb); c != 0 {
+				return c
+			}
+			continue
+		}
+		if ra[i] != rb[j] {
+			return int(ra[i]) - int(rb[j])
+		}
+		i++
+		j++
+	}
+	return (len(ra) - i) - (len(rb) - j)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSortDevices(t *testing.T) {
+	names := []string{
+		"Apple TV 4K", "iPhone 11", "iPad Pro", "iPhone 9", "MacBook Air",
+		"iPhone 15 Pro", "Apple Watch Series 10", "iPhone 15", "Apple Watch Series 9",
+		"HomePod", "iPad mini 6", "iPad Air",
+	}
+	sortDevices(names)
+	want := "iPhone 9, iPhone 11, iPhone 15, iPhone 15 Pro, iPad Air, iPad mini 6, iPad Pro, " +
+		"MacBook Air, Apple Watch Series 9, Apple Watch Series 10, Apple TV 4K, HomePod"
+	if got := strings.Join(names, ", "); got != want {
+		t.Errorf("sortDevices =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestNaturalCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"iPhone 9", "iPhone 11", -1},
+		{"iPhone 11", "iPhone 9", 1},
+		{"iPhone 15", "iphone 15", 0},
+		{"iPhone 15", "iPhone 15 Pro", -1},
+		{"iPad 007", "iPad 7", 0},
+		{"Series 10", "Series 9", 1},
+	}
+	for _, tt := range tests {
+		got := naturalCompare(tt.a, tt.b)
+		if (got < 0) != (tt.want < 0) || (got > 0) != (tt.want > 0) {
+			t.Errorf("naturalCompare(%q, %q) = %d, want sign of %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestDeviceCount(t *testing.T) {
+	const date = "Tue, 07 Nov 2023 18:00:00 +0000"
+	tests := []struct {
+		title string
+		n     int
+		cell  string
+	}{
+		{"tvOS 17.1 (21K69) has been released", 0, ""},
+		{"iPadOS 17.1 (21B74) for iPad Pro has been released", 1, "1 device"},
+		{"iOS 17.1.1 (21B91) for iPhone 15, iPhone 15 Pro has been released", 2, "2 devices"},
+		{"iOS 17.1 (21B74) for iPhone XR, iPhone XS, iPhone 11, iPhone 12 has been released", 4, "4 devices"},
+	}
+	for _, tt := range tests {
+		it := newItem(t, tt.title, date)
+		if len(it.Devices) != tt.n || toJSONItem(it).DeviceCount != tt.n {
+			t.Errorf("%q: %d devices %q, JSON deviceCount %d; want %d", tt.title, len(it.Devices), it.Devices, toJSONItem(it).DeviceCount, tt.n)
+		}
+		if got := deviceCountCell(it, 10, plainOptions(80), colorizer{}); got != pad(tt.cell, 10) {
+			t.Errorf("%q: cell %q, want %q", tt.title, got, pad(tt.cell, 10))
+		}
+	}
+}
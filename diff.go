@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+func runDiff(cfg Config) {
+	f := newFetcher(cfg)
+	oldItems, err := loadItems(f, cfg.DiffOld, normalizeOptionsFor(cfg))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitCode(err))
+	}
+	newItems, err := loadItems(f, cfg.DiffNew, normalizeOptionsFor(cfg))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitCode(err))
+	}
+
+	added, removed := diffItems(oldItems, newItems)
+	if err := renderDiff(added, removed, cfg, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "write error:", err)
+		os.Exit(exitError)
+	}
+}
+
+// itemID is the identity used to match items across feeds: the GUID when
+// present, otherwise the link, otherwise the raw title.
+func itemID(it Item) string {
+	if id := strings.TrimSpace(it.GUID); id != "" {
+		return id
+	}
+	if link := strings.TrimSpace(it.Link); link != "" {
+		return link
+	}
+	return it.Title
+}
+
+// diffItems reports items only present in newItems (added) and items only
+// present in oldItems (removed), each sorted newest first.
+func diffItems(oldItems, newItems []Item) (added, removed []Item) {
+	oldIDs := make(map[string]bool, len(oldItems))
+	for _, it := range oldItems {
+		oldIDs[itemID(it)] = true
+	}
+	newIDs := make(map[string]bool, len(newItems))
+	for _, it := range newItems {
+		newIDs[itemID(it)] = true
+		if !oldIDs[itemID(it)] {
+			added = append(added, it)
+		}
+	}
+	for _, it := range oldItems {
+		if !newIDs[itemID(it)] {
+			removed = append(removed, it)
+		}
+	}
+
+	byDate := func(items []Item) {
+		sort.Slice(items, func(i, j int) bool {
+			return items[i].PubDate.After(items[j].PubDate)
+		})
+	}
+	byDate(added)
+	byDate(removed)
+	return added, removed
+}
+
+// jsonDiff is the -format json output of diff.
+type jsonDiff struct {
+	Added   []jsonItem `json:"added"`
+	Removed []jsonItem `json:"removed"`
+}
+
+// renderDiff prints the changes as a table with a +/- gutter, removals in
+// red and additions in green, newest first; removals come first among
+// changes published at the same time. Nothing is printed when the feeds
+// match.
+func renderDiff(added, removed []Item, cfg Config, out io.Writer) error {
+	if cfg.Format == "json" {
+		diff := jsonDiff{Added: []jsonItem{}, Removed: []jsonItem{}}
+		for _, it := range added {
+			diff.Added = append(diff.Added, toJSONItem(it))
+		}
+		for _, it := range removed {
+			diff.Removed = append(diff.Removed, toJSONItem(it))
+		}
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(diff)
+	}
+
+	rows := make([]Item, 0, len(added)+len(removed))
+	for _, it := range removed {
+		it.Change = "removed"
+		rows = append(rows, it)
+	}
+	for _, it := range added {
+		it.Change = "added"
+		rows = append(rows, it)
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].PubDate.After(rows[j].PubDate)
+	})
+
+	opts := tableOptions(cfg)
+	opts.Gutter = true
+	renderTable(rows, opts, out)
+	return nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderDiffColored(t *testing.T) {
+	t.Setenv("COLUMNS", "80")
+	t.Setenv("LANG", "en_US.UTF-8")
+	feed := loadFixture(t, "timeline.rss")
+	oldItems := feed[1:]
+	newItems := append(feed[:4:4], newItem(t, "iOS 17.2 (21C62) for iPhone 15 has been released", "Mon, 11 Dec 2023 18:00:00 +0000"))
+
+	added, removed := diffItems(oldItems, newItems)
+	if len(added) != 2 || len(removed) != 1 {
+		t.Fatalf("%d added, %d removed; want 2 and 1", len(added), len(removed))
+	}
+
+	cfg := Config{Color: "always", Indent: 2, Gap: 1, Divider: "dashes", Ellipsis: "…", Sort: "date", Now: testNow}
+	var b strings.Builder
+	if err := renderDiff(added, removed, cfg, &b); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	checkFits(t, out, 80)
+	checkGolden(t, "diff-color", strings.ReplaceAll(out, "\033", `\e`))
+
+	b.Reset()
+	if err := renderDiff(nil, nil, cfg, &b); err != nil || b.Len() != 0 {
+		t.Errorf("matching feeds printed %q (err %v), want nothing", b.String(), err)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// doctorSampleSize is how many parsed items doctor prints per feed.
+const doctorSampleSize = 3
+
+// doctor collects the outcome of each diagnostic check.
+type doctor struct {
+	out    io.Writer
+	failed int
+	passed int
+}
+
+func (d *doctor) pass(format string, args ...any) {
+	d.passed++
+	fmt.Fprintf(d.out, "  [ok]   "+format+"\n", args...)
+}
+
+func (d *doctor) fail(format string, args ...any) {
+	d.failed++
+	fmt.Fprintf(d.out, "  [FAIL] "+format+"\n", args...)
+}
+
+func (d *doctor) info(format string, args ...any) {
+	fmt.Fprintf(d.out, "  [info] "+format+"\n", args...)
+}
+
+// runDoctor checks the environment and runs the full fetch and parse
+// pipeline for every configured feed with verbose diagnostics. It only
+// reads: nothing is written to disk.
+func runDoctor(cfg Config) {
+	d := &doctor{out: os.Stdout}
+
+	fmt.Fprintln(d.out, "Environment")
+	if exe, err := os.Executable(); err == nil {
+		d.info("executable: %s", exe)
+	}
+	if wd, err := os.Getwd(); err == nil {
+		d.info("working directory: %s", wd)
+	}
+	d.info("locale: %s (unicode: %t)", localeSummary(), unicodeSupported())
+	d.info("stdout is a terminal: %t", isTTY())
+	d.info("color: %s -> enabled: %t (NO_COLOR set: %t)", cfg.Color, shouldEnableColor(cfg.Color), os.Getenv("NO_COLOR") != "")
+	d.info("terminal width: %d", terminalWidth())
+	if cfg.ConfigPath != "" {
+		if _, err := os.Stat(cfg.ConfigPath); err == nil {
+			d.pass("config file: %s (%d custom platforms)", cfg.ConfigPath, len(customPlatforms))
+		} else {
+			d.info("config file: %s (not present)", cfg.ConfigPath)
+		}
+	}
+
+	if cfg.CacheTTL > 0 || cfg.Revalidate {
+		d.info("cache directory: %s (ttl %s, revalidate %t; doctor bypasses it)", cfg.CacheDir, cfg.CacheTTL, cfg.Revalidate)
+	}
+
+	// Doctor must not change any state, so it never touches the cache and
+	// ignores -dump-raw.
+	f := newFetcher(cfg)
+	f.cache = nil
+	f.dumpPaths = nil
+	for _, feedURL := range cfg.Feeds {
+		d.checkFeed(f, feedURL, cfg)
+	}
+	if cfg.ExpectedFeed != "" {
+		d.checkFeed(f, cfg.ExpectedFeed, cfg)
+	}
+
+	fmt.Fprintf(d.out, "\n%d passed, %d failed\n", d.passed, d.failed)
+	if d.failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func (d *doctor) checkFeed(f *fetcher, feedURL string, cfg Config) {
+	fmt.Fprintf(d.out, "\nFeed %s\n", feedURL)
+	if strings.HasPrefix(feedURL, "file://") {
+		d.info("local file: %s", strings.TrimPrefix(feedURL, "file://"))
+	}
+
+	start := time.Now()
+	resp, err := f.fetchFeed(context.Background(), feedURL)
+	if err != nil {
+		d.fail("fetch: %v", err)
+		return
+	}
+	d.pass("fetch: %d bytes in %s", len(resp.Body), time.Since(start).Round(time.Millisecond))
+
+	start = time.Now()
+	rawItems, err := parseFeed(resp.Body, cfg.FeedFormat)
+	if err != nil {
+		d.fail("parse: %v", err)
+		return
+	}
+	d.pass("parse: %d items in %s", len(rawItems), time.Since(start).Round(time.Millisecond))
+
+	if len(rawItems) == 0 {
+		d.fail("feed has no items")
+		return
+	}
+
+	items := make([]Item, 0, doctorSampleSize)
+	for _, r := range rawItems[:min(doctorSampleSize, len(rawItems))] {
+		items = append(items, normalizeItem(r, normalizeOptionsFor(cfg)))
+	}
+	fmt.Fprintf(d.out, "  first %d items:\n", len(items))
+	renderTable(items, tableOptions(cfg), d.out)
+}
+
+func localeSummary() string {
+	var parts []string
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if v := os.Getenv(name); v != "" {
+			parts = append(parts, name+"="+v)
+		}
+	}
+	if len(parts) == 0 {
+		return "unset"
+	}
+	return strings.Join(parts, " ")
+}
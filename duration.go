@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	day  = 24 * time.Hour
+	week = 7 * day
+)
+
+// durationUnits are the units parseHumanDuration understands: Go's own plus
+// days and weeks.
+var durationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"µs": time.Microsecond,
+	"μs": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+	"d":  day,
+	"w":  week,
+}
+
+// parseHumanDuration parses a duration such as "30d", "2w" or "1d12h". It
+// accepts everything time.ParseDuration does, with d (24h) and w (7d) on
+// top; a day is always 24 hours, whatever the calendar says.
+func parseHumanDuration(s string) (time.Duration, error) {
+	orig := s
+	s = strings.TrimSpace(s)
+	invalid := fmt.Errorf("invalid duration %q: use e.g. 36h, 30d, 1d12h or 2w", orig)
+
+	neg := false
+	if s != "" && (s[0] == '-' || s[0] == '+') {
+		neg = s[0] == '-'
+		s = s[1:]
+	}
+	if s == "0" {
+		return 0, nil
+	}
+	if s == "" {
+		return 0, invalid
+	}
+
+	var total uint64
+	for s != "" {
+		i := 0
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		whole, frac := s[:i], ""
+		if i < len(s) && s[i] == '.' {
+			j := i + 1
+			for j < len(s) && s[j] >= '0' && s[j] <= '9' {
+				j++
+			}
+			frac, i = s[i+1:j], j
+		}
+		if whole == "" && frac == "" {
+			return 0, invalid
+		}
+		j := i
+		for j < len(s) && s[j] != '.' && (s[j] < '0' || s[j] > '9') {
+			j++
+		}
+		unit, ok := durationUnits[s[i:j]]
+		if !ok {
+			return 0, invalid
+		}
+		s = s[j:]
+
+		n := uint64(0)
+		if whole != "" {
+			var err error
+			if n, err = strconv.ParseUint(whole, 10, 64); err != nil {
+				return 0, fmt.Errorf("duration %q is too long", orig)
+			}
+		}
+		if n > math.MaxInt64/uint64(unit) {
+			return 0, fmt.Errorf("duration %q is too long", orig)
+		}
+		part := n * uint64(unit)
+		if frac != "" {
+			f, _ := strconv.ParseFloat("0."+frac, 64)
+			part += uint64(f * float64(unit))
+		}
+		if part > 1<<63-total || (total+part == 1<<63 && !neg) {
+			return 0, fmt.Errorf("duration %q is too long", orig)
+		}
+		total += part
+	}
+	if neg {
+		return -time.Duration(total), nil
+	}
+	return time.Duration(total), nil
+}
+
+// formatHumanDuration is the inverse of parseHumanDuration, using weeks and
+// days where they fit, such as "1w3d" or "1d12h".
+func formatHumanDuration(d time.Duration) string {
+	if d == 0 {
+		return "0"
+	}
+	var b strings.Builder
+	if d < 0 {
+		if d == math.MinInt64 {
+			return d.String()
+		}
+		b.WriteByte('-')
+		d = -d
+	}
+	for _, u := range []struct {
+		name string
+		size time.Duration
+	}{{"w", week}, {"d", day}, {"h", time.Hour}, {"m", time.Minute}, {"s", time.Second}} {
+		if n := d / u.size; n > 0 && (u.size > time.Second || d%time.Second == 0) {
+			fmt.Fprintf(&b, "%d%s", n, u.name)
+			d -= n * u.size
+		}
+		if u.size == time.Second && d > 0 {
+			b.WriteString(d.String())
+		}
+	}
+	return b.String()
+}
+
+// durationValue is a flag holding a duration given with parseHumanDuration.
+type durationValue time.Duration
+
+func (v *durationValue) String() string {
+	if v == nil {
+		return "0"
+	}
+	return formatHumanDuration(time.Duration(*v))
+}
+
+func (v *durationValue) Set(s string) error {
+	d, err := parseHumanDuration(s)
+	if err != nil {
+		return err
+	}
+	*v = durationValue(d)
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseHumanDuration(t *testing.T) {
+	for in, want := range map[string]time.Duration{
+		"0":      0,
+		"90s":    90 * time.Second,
+		"36h":    36 * time.Hour,
+		"1.5h":   90 * time.Minute,
+		"30d":    30 * day,
+		"2w":     2 * week,
+		"1w3d":   10 * day,
+		"1d12h":  36 * time.Hour,
+		" 3d ":   3 * day,
+		"-1d":    -day,
+		"+2h30m": 150 * time.Minute,
+		"0.5d":   12 * time.Hour,
+		"250ms":  250 * time.Millisecond,
+	} {
+		if got, err := parseHumanDuration(in); err != nil || got != want {
+			t.Errorf("parseHumanDuration(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+
+	for _, in := range []string{"", "d", "3", "3x", "1y", "1d-2h", "..5h", "-", "3 d"} {
+		if _, err := parseHumanDuration(in); err == nil || !strings.Contains(err.Error(), "invalid duration") {
+			t.Errorf("parseHumanDuration(%q): err = %v, want invalid", in, err)
+		}
+	}
+	for _, in := range []string{"15251w", "106752d", "9999999999999999999999h", "106751d106751d"} {
+		if _, err := parseHumanDuration(in); err == nil || !strings.Contains(err.Error(), "too long") {
+			t.Errorf("parseHumanDuration(%q): err = %v, want too long", in, err)
+		}
+	}
+	if got, err := parseHumanDuration("15250w"); err != nil || got != 15250*week {
+		t.Errorf("parseHumanDuration(15250w) = %v, %v; just under the limit", got, err)
+	}
+}
+
+func TestFormatHumanDuration(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		0:                       "0",
+		36 * time.Hour:          "1d12h",
+		10 * day:                "1w3d",
+		-day:                    "-1d",
+		90 * time.Second:        "1m30s",
+		1500 * time.Millisecond: "1.5s",
+	} {
+		got := formatHumanDuration(d)
+		if got != want {
+			t.Errorf("formatHumanDuration(%v) = %q, want %q", d, got, want)
+		}
+		if back, err := parseHumanDuration(got); err != nil || back != d {
+			t.Errorf("parseHumanDuration(%q) = %v, %v; want %v back", got, back, err, d)
+		}
+	}
+}
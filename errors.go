@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Exit codes used by the CLI. Flag syntax errors exit with 2, as the flag
+// package does.
+const (
+	exitError           = 1
+	exitFetchError      = 3
+	exitStatusError     = 4
+	exitParseError      = 5
+	exitEmpty           = 6
+	exitUnknownPlatform = 7
+)
+
+// FetchError reports that a feed could not be retrieved: a network failure,
+// a timeout or an unreadable local file.
+type FetchError struct {
+	URL string
+	Err error
+}
+
+func (e *FetchError) Error() string {
+	return fmt.Sprintf("fetch error (%s): %v", e.URL, e.Err)
+}
+
+func (e *FetchError) Unwrap() error { return e.Err }
+
+// Timeout reports whether the fetch failed because a deadline was exceeded.
+func (e *FetchError) Timeout() bool {
+	var netErr net.Error
+	return errors.As(e.Err, &netErr) && netErr.Timeout()
+}
+
+// HTTPStatusError reports a response with a non-2xx status code.
+type HTTPStatusError struct {
+	URL  string
+	Code int
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("fetch error (%s): unexpected status %d", e.URL, e.Code)
+}
+
+// ParseError reports a feed body that could not be decoded.
+type ParseError struct {
+	URL string
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	if e.URL == "" {
+		return fmt.Sprintf("parse error: %v", e.Err)
+	}
+	return fmt.Sprintf("parse error (%s): %v", e.URL, e.Err)
+}
+
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// EmptyFeedError reports feeds that loaded and parsed but, between them,
+// hold no items at all.
+type EmptyFeedError struct {
+	URLs []string
+}
+
+func (e *EmptyFeedError) Error() string {
+	return "empty feed: no items in " + strings.Join(e.URLs, ", ")
+}
+
+// UnknownPlatformError reports platform names that -strict-platforms
+// refused to bucket as "other".
+type UnknownPlatformError struct {
+	Labels []string
+}
+
+func (e *UnknownPlatformError) Error() string {
+	quoted := make([]string, len(e.Labels))
+	for i, l := range e.Labels {
+		quoted[i] = strconv.Quote(l)
+	}
+	return "unknown platform: " + strings.Join(quoted, ", ")
+}
+
+// exitCode maps an error to the process exit code.
+func exitCode(err error) int {
+	var statusErr *HTTPStatusError
+	var fetchErr *FetchError
+	var parseErr *ParseError
+	var platformErr *UnknownPlatformError
+	var emptyErr *EmptyFeedError
+	switch {
+	case errors.As(err, &statusErr):
+		return exitStatusError
+	case errors.As(err, &fetchErr):
+		return exitFetchError
+	case errors.As(err, &parseErr):
+		return exitParseError
+	case errors.As(err, &platformErr):
+		return exitUnknownPlatform
+	case errors.As(err, &emptyErr):
+		return exitEmpty
+	default:
+		return exitError
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFetchErrorTypes(t *testing.T) {
+	silence(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			http.NotFound(w, r)
+		case "/slow":
+			time.Sleep(500 * time.Millisecond)
+		case "/malformed":
+			w.Write([]byte("<rss><channel><item><title>iOS 17.1"))
+		}
+	}))
+	defer srv.Close()
+
+	cfg := testConfig(t.TempDir())
+	cfg.NoStaleFallback = true
+	cfg.Timeout = 50 * time.Millisecond
+
+	_, err := loadItems(newFetcher(cfg), srv.URL+"/missing", testNormalizeOptions(t))
+	var statusErr *HTTPStatusError
+	if !errors.As(err, &statusErr) || statusErr.Code != http.StatusNotFound {
+		t.Errorf("404: err = %#v, want an HTTPStatusError with code 404", err)
+	}
+	if exitCode(err) != exitStatusError {
+		t.Errorf("404: exit code = %d, want %d", exitCode(err), exitStatusError)
+	}
+
+	_, err = loadItems(newFetcher(cfg), srv.URL+"/slow", testNormalizeOptions(t))
+	var fetchErr *FetchError
+	if !errors.As(err, &fetchErr) || !fetchErr.Timeout() {
+		t.Errorf("timeout: err = %#v, want a FetchError that timed out", err)
+	}
+	if exitCode(err) != exitFetchError {
+		t.Errorf("timeout: exit code = %d, want %d", exitCode(err), exitFetchError)
+	}
+
+	_, err = loadItems(newFetcher(cfg), srv.URL+"/malformed", testNormalizeOptions(t))
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Errorf("malformed XML: err = %#v, want a ParseError", err)
+	}
+	if exitCode(err) != exitParseError {
+		t.Errorf("malformed XML: exit code = %d, want %d", exitCode(err), exitParseError)
+	}
+}
+
+func TestEmptyFeedError(t *testing.T) {
+	silence(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<?xml version="1.0"?><rss version="2.0"><channel><title>Empty</title></channel></rss>`))
+	}))
+	defer srv.Close()
+
+	cfg := testConfig(t.TempDir())
+	cfg.Feeds = []string{srv.URL}
+	cfg.FeedFormat = "auto"
+	_, err := loadFeeds(newFetcher(cfg), cfg)
+	var emptyErr *EmptyFeedError
+	if !errors.As(err, &emptyErr) {
+		t.Fatalf("err = %#v, want an EmptyFeedError", err)
+	}
+	if exitCode(err) != exitEmpty {
+		t.Errorf("exit code = %d, want %d", exitCode(err), exitEmpty)
+	}
+	if allowEmpty(err) != nil {
+		t.Errorf("allowEmpty(%v) = %v, want nil", err, allowEmpty(err))
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// exportFormats are the formats -output-dir can write, with the extension
+// of each file.
+var exportFormats = map[string]string{
+	"json": ".json",
+	"rss":  ".rss",
+	"html": ".html",
+}
+
+const (
+	exportTitle       = "IPSW Timeline"
+	exportDescription = "Recent Apple firmware releases"
+)
+
+type rssOut struct {
+	XMLName xml.Name      `xml:"rss"`
+	Version string        `xml:"version,attr"`
+	Channel rssOutChannel `xml:"channel"`
+}
+
+type rssOutChannel struct {
+	Title       string       `xml:"title"`
+	Description string       `xml:"description"`
+	Items       []rssOutItem `xml:"item"`
+}
+
+type rssOutItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link,omitempty"`
+	GUID        string `xml:"guid,omitempty"`
+	PubDate     string `xml:"pubDate"`
+	Description string `xml:"description,omitempty"`
+}
+
+// renderRSS writes items back out as an RSS 2.0 feed, with their original
+// titles and descriptions.
+func renderRSS(items []Item, out io.Writer) error {
+	doc := rssOut{Version: "2.0", Channel: rssOutChannel{Title: exportTitle, Description: exportDescription}}
+	for _, it := range items {
+		doc.Channel.Items = append(doc.Channel.Items, rssOutItem{
+			Title:       it.Title,
+			Link:        it.Link,
+			GUID:        it.GUID,
+			PubDate:     it.PubDate.UTC().Format(time.RFC1123Z),
+			Description: it.Description,
+		})
+	}
+	if _, err := io.WriteString(out, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(out)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(out, "\n")
+	return err
+}
+
+var htmlTemplate = template.Must(template.New("timeline").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<table>
+<thead><tr><th>Published</th><th>Platform</th><th>{{.VersionHeader}}</th><th>Device / Notes</th></tr></thead>
+<tbody>
+{{- range .Rows}}
+<tr><td><time datetime="{{.Date}}">{{.Published}}</time></td><td>{{.Platform}}</td><td>{{if .Link}}<a href="{{.Link}}">{{.Version}}</a>{{else}}{{.Version}}{{end}}</td><td>{{.Device}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+</body>
+</html>
+`))
+
+type htmlRow struct {
+	Date, Published, Platform, Version, Link, Device string
+}
+
+// renderHTML writes items as a standalone HTML page with one table row per
+// release, linking each version to its download page.
+func renderHTML(items []Item, buildFirst bool, out io.Writer) error {
+	page := struct {
+		Title, VersionHeader string
+		Rows                 []htmlRow
+	}{Title: exportTitle, VersionHeader: "Version (Build)"}
+	if buildFirst {
+		page.VersionHeader = "Build (Version)"
+	}
+	for _, it := range items {
+		page.Rows = append(page.Rows, htmlRow{
+			Date:      it.PubDate.UTC().Format(time.RFC3339),
+			Published: it.PubDate.UTC().Format("2006-01-02 15:04 UTC"),
+			Platform:  it.PlatformLabel,
+			Version:   buildVersion(it.Version, it.Build, buildFirst),
+			Link:      it.Link,
+			Device:    it.DeviceOrNotes,
+		})
+	}
+	return htmlTemplate.Execute(out, page)
+}
+
+// writeOutput writes what renderItems would print to cfg.Output instead,
+// replacing the file atomically.
+func writeOutput(items []Item, feeds []Feed, cfg Config) error {
+	var buf bytes.Buffer
+	if err := renderItems(items, feeds, cfg, &buf); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(cfg.Output, buf.Bytes()); err != nil {
+		return fmt.Errorf("output error: %w", err)
+	}
+	if err := os.Chmod(cfg.Output, 0o644); err != nil {
+		return fmt.Errorf("output error: %w", err)
+	}
+	return nil
+}
+
+// writeOutputDir writes timeline.<ext> into dir for each of cfg.Formats,
+// rendered as -format would with color off. Each file is replaced
+// atomically, so a web server never serves a half-written one.
+func writeOutputDir(items []Item, feeds []Feed, cfg Config) error {
+	if err := os.MkdirAll(cfg.OutputDir, 0o755); err != nil {
+		return fmt.Errorf("output error: %w", err)
+	}
+	for _, format := range cfg.Formats {
+		fcfg := cfg
+		fcfg.Format = format
+		fcfg.Porcelain = false
+		fcfg.Color = "never"
+		var buf bytes.Buffer
+		if err := renderItems(items, feeds, fcfg, &buf); err != nil {
+			return err
+		}
+		path := filepath.Join(cfg.OutputDir, "timeline"+exportFormats[format])
+		if err := writeFileAtomic(path, buf.Bytes()); err != nil {
+			return fmt.Errorf("output error: %w", err)
+		}
+		if err := os.Chmod(path, 0o644); err != nil {
+			return fmt.Errorf("output error: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteOutputDir(t *testing.T) {
+	items := loadFixture(t, "timeline.rss")
+	cfg := Config{
+		OutputDir: filepath.Join(t.TempDir(), "site"),
+		Formats:   []string{"json", "rss", "html"},
+		JSONArray: true,
+	}
+	if err := writeOutputDir(items, nil, cfg); err != nil {
+		t.Fatal(err)
+	}
+	read := func(name string) []byte {
+		t.Helper()
+		path := filepath.Join(cfg.OutputDir, name)
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0o644 {
+			t.Errorf("%s has mode %v, want 0644", name, info.Mode().Perm())
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	var decoded []map[string]any
+	if err := json.Unmarshal(read("timeline.json"), &decoded); err != nil {
+		t.Fatalf("timeline.json: %v", err)
+	}
+	if len(decoded) != len(items) || decoded[0]["build"] != "21B91" {
+		t.Errorf("timeline.json has %d items, first %v; want %d starting with 21B91", len(decoded), decoded[0], len(items))
+	}
+
+	raw, err := parseFeed(read("timeline.rss"), "rss")
+	if err != nil {
+		t.Fatalf("timeline.rss: %v", err)
+	}
+	if len(raw) != len(items) || raw[0].Title != items[0].Title {
+		t.Errorf("timeline.rss has %d items, first %q; want %d starting with %q", len(raw), raw[0].Title, len(items), items[0].Title)
+	}
+
+	page := string(read("timeline.html"))
+	if n := strings.Count(page, "<tr><td>"); n != len(items) {
+		t.Errorf("timeline.html has %d rows, want %d", n, len(items))
+	}
+	if !strings.Contains(page, "17.1.1 (21B91)") || strings.Contains(page, "\033[") {
+		t.Errorf("timeline.html lacks 17.1.1 (21B91) or contains color codes:\n%s", page)
+	}
+}
@@ -0,0 +1,510 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// fetcher retrieves feed bodies. A run builds one fetcher and shares it
+// across every fetch, including all watch cycles, so keep-alive connections
+// are reused instead of being re-established each time.
+type fetcher struct {
+	client  *http.Client
+	maxSize int64
+	cache   *feedCache
+	// parsed holds normalized items by feed content for -cache-parsed.
+	parsed *feedCache
+	// lastGood keeps the last body of each feed that parsed, for the stale
+	// fallback. It is the same directory as cache, but set even without a
+	// TTL.
+	lastGood   *feedCache
+	cacheTTL   time.Duration
+	revalidate bool
+	dumpPaths  map[string]string
+
+	retries        int
+	attemptTimeout time.Duration
+	deadline       time.Duration
+	jitter         bool
+	// trace logs each request's stages to stderr (-trace).
+	trace bool
+
+	// staleFallback serves the cached copy when a fresh body won't parse.
+	staleFallback bool
+	// offline serves HTTP feeds from the cache whatever their age and never
+	// makes a request (-offline).
+	offline bool
+
+	// With followNext, feeds that link to a next page are read up to
+	// maxPages pages deep.
+	followNext bool
+	maxPages   int
+
+	// staleAfter is the -stale-after age past which a feed's
+	// lastBuildDate draws a warning.
+	staleAfter time.Duration
+	// clock is the -now time, or zero for the real one. It only affects
+	// judgments about feed content; cache ages always use the real clock.
+	clock time.Time
+
+	// feeds is the metadata of every feed loaded so far, in load order.
+	feeds []Feed
+	// stats tallies the run for the -verbose summary.
+	stats fetchStats
+}
+
+// fetchStats is what a run spent fetching and parsing feeds.
+type fetchStats struct {
+	fetchTime   time.Duration
+	parseTime   time.Duration
+	bytes       int64
+	cacheHits   int
+	cacheMisses int
+	rawItems    int
+}
+
+// summary is the -verbose line printed after a run: time spent, bytes
+// downloaded, cache use and item counts through the pipeline.
+func (s fetchStats) summary(filtered, shown int) string {
+	return fmt.Sprintf("fetch %s, parse %s, %s downloaded, cache %d hit/%d miss; items %d raw, %d after filters, %d shown",
+		s.fetchTime.Round(time.Microsecond), s.parseTime.Round(time.Microsecond), formatBytes(s.bytes),
+		s.cacheHits, s.cacheMisses, s.rawItems, filtered, shown)
+}
+
+// formatBytes renders n with a binary unit, such as "512 B" or "3.4 KiB".
+func formatBytes(n int64) string {
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+	size, unit := float64(n)/1024, "KiB"
+	for _, next := range []string{"MiB", "GiB"} {
+		if size < 1024 {
+			break
+		}
+		size, unit = size/1024, next
+	}
+	return fmt.Sprintf("%.1f %s", size, unit)
+}
+
+// feedResponse is a fetched feed body plus what is needed to cache it.
+// FromCache is set when the body came from the cache, either because the
+// entry was fresh or because the server answered 304 Not Modified.
+type feedResponse struct {
+	Body         []byte
+	ETag         string
+	LastModified string
+	FromCache    bool
+}
+
+func newFetcher(cfg Config) *fetcher {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = cfg.MaxIdleConns
+	transport.MaxIdleConnsPerHost = cfg.MaxIdleConns
+	transport.IdleConnTimeout = cfg.IdleConnTimeout
+	if cfg.ConnectTimeout > 0 {
+		dialer := &net.Dialer{Timeout: cfg.ConnectTimeout, KeepAlive: 30 * time.Second}
+		transport.DialContext = dialer.DialContext
+		transport.TLSHandshakeTimeout = cfg.ConnectTimeout
+	}
+	transport.ResponseHeaderTimeout = cfg.HeaderTimeout
+	if cfg.HTTP1 {
+		// A non-nil, empty TLSNextProto map is how net/http is told not to
+		// negotiate HTTP/2.
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	// Timeouts are applied per request through contexts rather than with
+	// http.Client.Timeout, so retries each get their own budget.
+	attemptTimeout := cfg.AttemptTimeout
+	if attemptTimeout <= 0 {
+		attemptTimeout = cfg.Timeout
+	}
+
+	f := &fetcher{
+		client:         &http.Client{Transport: transport},
+		maxSize:        cfg.MaxFeedSize,
+		cacheTTL:       cfg.CacheTTL,
+		revalidate:     cfg.Revalidate,
+		retries:        cfg.Retries,
+		attemptTimeout: attemptTimeout,
+		deadline:       cfg.Deadline,
+		jitter:         !cfg.NoJitter,
+		staleFallback:  !cfg.NoStaleFallback,
+		offline:        cfg.Offline,
+		followNext:     cfg.FollowNext,
+		maxPages:       cfg.MaxPages,
+		staleAfter:     cfg.StaleAfter,
+		trace:          cfg.Trace && !cfg.Quiet,
+		clock:          cfg.Now,
+	}
+	if (cfg.CacheTTL > 0 || cfg.Revalidate || cfg.Offline) && cfg.CacheDir != "" {
+		f.cache = &feedCache{dir: cfg.CacheDir}
+	}
+	if cfg.CacheParsed && cfg.CacheDir != "" {
+		f.parsed = &feedCache{dir: cfg.CacheDir}
+	}
+	if f.cache != nil || (f.staleFallback && cfg.CacheDir != "") {
+		f.lastGood = &feedCache{dir: cfg.CacheDir}
+	}
+	if cfg.DumpRaw != "" {
+		f.dumpPaths = make(map[string]string, len(cfg.Feeds))
+		for i, feedURL := range cfg.Feeds {
+			f.dumpPaths[feedURL] = numberedPath(cfg.DumpRaw, i)
+		}
+	}
+	return f
+}
+
+// numberedPath returns path for the first feed and inserts the feed's
+// 1-based number before the extension for the others: feed.xml, feed.2.xml.
+func numberedPath(path string, index int) string {
+	if index == 0 {
+		return path
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + strconv.Itoa(index+1) + ext
+}
+
+// fetchFeed fetches url and, with -dump-raw, saves the exact body before it
+// is parsed. Nothing is written when the fetch fails.
+func (f *fetcher) fetchFeed(ctx context.Context, url string) (*feedResponse, error) {
+	start := time.Now()
+	resp, err := f.fetchBody(ctx, url)
+	f.stats.fetchTime += time.Since(start)
+	if err != nil {
+		var statusErr *HTTPStatusError
+		if errors.As(err, &statusErr) {
+			return nil, err
+		}
+		return nil, &FetchError{URL: url, Err: err}
+	}
+	switch {
+	case resp.FromCache:
+		f.stats.cacheHits++
+	case f.cache != nil:
+		f.stats.cacheMisses++
+	}
+	if !resp.FromCache {
+		f.stats.bytes += int64(len(resp.Body))
+	}
+	if path, ok := f.dumpPaths[url]; ok {
+		if err := os.WriteFile(path, resp.Body, 0o644); err != nil {
+			return nil, fmt.Errorf("dump-raw: %w", err)
+		}
+	}
+	return resp, nil
+}
+
+// parsePage is parseFeedPage, timed for the -verbose summary.
+func (f *fetcher) parsePage(data []byte, format string) (feedPage, error) {
+	start := time.Now()
+	page, err := parseFeedPage(data, format)
+	f.stats.parseTime += time.Since(start)
+	return page, err
+}
+
+// errOffline is the reason an HTTP feed can't be loaded under -offline.
+var errOffline = errors.New("offline and no cached copy: use a file:// feed, or fetch it once online")
+
+// fetchBody returns the body of url. With a cache configured, a cached
+// entry younger than the TTL is returned without a request unless
+// revalidation is on; otherwise a conditional request is made using the
+// cached validators and a 304 restarts the entry's TTL. Offline, any cached
+// entry is returned and a miss is errOffline. Fresh downloads are not
+// cached here: call commit once the body has parsed. -deadline bounds the
+// fetch on top of whatever deadline ctx already carries.
+func (f *fetcher) fetchBody(ctx context.Context, url string) (*feedResponse, error) {
+	if strings.HasPrefix(url, "file://") {
+		file, err := os.Open(strings.TrimPrefix(url, "file://"))
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		body, err := readLimited(file, f.maxSize)
+		if err != nil {
+			return nil, err
+		}
+		return &feedResponse{Body: body}, nil
+	}
+
+	var meta *cacheMeta
+	var cached []byte
+	if f.cache != nil {
+		meta, cached, _ = f.cache.load(url)
+		if meta != nil && f.offline {
+			return &feedResponse{Body: cached, ETag: meta.ETag, LastModified: meta.LastModified, FromCache: true}, nil
+		}
+		if meta != nil && !f.revalidate && f.cacheTTL > 0 && time.Since(meta.FetchedAt) < f.cacheTTL {
+			return &feedResponse{Body: cached, ETag: meta.ETag, LastModified: meta.LastModified, FromCache: true}, nil
+		}
+	}
+
+	if f.offline {
+		return nil, errOffline
+	}
+
+	if f.deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.deadline)
+		defer cancel()
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := f.fetchHTTP(ctx, url, meta, cached)
+		if err == nil || attempt >= f.retries || !retryable(err) || ctx.Err() != nil {
+			return resp, err
+		}
+
+		wait := backoff(attempt)
+		if f.jitter {
+			wait = jitter(wait)
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return nil, err
+		}
+		time.Sleep(wait)
+	}
+}
+
+// fetchHTTP makes a single request, bounded by the per-attempt timeout and
+// by whatever deadline ctx already carries.
+func (f *fetcher) fetchHTTP(ctx context.Context, url string, meta *cacheMeta, cached []byte) (*feedResponse, error) {
+	if f.attemptTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.attemptTimeout)
+		defer cancel()
+	}
+
+	if f.trace {
+		ctx = withTrace(ctx, url)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "ipsw-timeline-cli/1.0 (+https://ipsw.me)")
+	if meta != nil {
+		if meta.ETag != "" {
+			req.Header.Set("If-None-Match", meta.ETag)
+		}
+		if meta.LastModified != "" {
+			req.Header.Set("If-Modified-Since", meta.LastModified)
+		}
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && meta != nil {
+		meta.FetchedAt = time.Now()
+		if err := f.cache.touch(url, *meta); err != nil {
+			fmt.Fprintf(os.Stderr, "cache error: %v\n", err)
+		}
+		return &feedResponse{Body: cached, ETag: meta.ETag, LastModified: meta.LastModified, FromCache: true}, nil
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, &HTTPStatusError{URL: url, Code: resp.StatusCode}
+	}
+
+	body, err := readLimited(resp.Body, f.maxSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &feedResponse{
+		Body:         body,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}, nil
+}
+
+// staleFeed is a cached copy served in place of a feed that didn't parse.
+type staleFeed struct {
+	items     []rawItem
+	fetchedAt time.Time
+}
+
+// staleItems returns the parsed cached copy of url, if there is one. Only
+// bodies that parsed are ever committed, so the copy is the last good one.
+func (f *fetcher) staleItems(url, format string) (staleFeed, bool) {
+	if f.lastGood == nil || !f.staleFallback {
+		return staleFeed{}, false
+	}
+	meta, body, err := f.lastGood.load(url)
+	if err != nil {
+		return staleFeed{}, false
+	}
+	items, err := parseFeed(body, format)
+	if err != nil {
+		return staleFeed{}, false
+	}
+	return staleFeed{items: items, fetchedAt: meta.FetchedAt}, true
+}
+
+func (f *fetcher) now() time.Time {
+	if f.clock.IsZero() {
+		return time.Now()
+	}
+	return f.clock
+}
+
+// recordFeed remembers the metadata of a loaded feed, replacing what an
+// earlier load of the same URL recorded.
+func (f *fetcher) recordFeed(feed Feed) {
+	for i := range f.feeds {
+		if f.feeds[i].URL == feed.URL {
+			f.feeds[i] = feed
+			return
+		}
+	}
+	f.feeds = append(f.feeds, feed)
+}
+
+// commit caches a freshly downloaded body as the feed's last good copy. It
+// is a no-op with neither a cache nor the stale fallback, for bodies that
+// came from the cache, and for local files.
+func (f *fetcher) commit(url string, resp *feedResponse) error {
+	if f.lastGood == nil || resp.FromCache || strings.HasPrefix(url, "file://") {
+		return nil
+	}
+	meta := cacheMeta{
+		ETag:         resp.ETag,
+		LastModified: resp.LastModified,
+		FetchedAt:    time.Now(),
+	}
+	return f.lastGood.store(url, meta, resp.Body)
+}
+
+// retryable reports whether a failed attempt is worth repeating: server
+// errors, rate limiting, timeouts, failures to connect and connections that
+// drop mid-response. Client errors and oversized bodies fail the same way
+// every time.
+func retryable(err error) bool {
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Code >= 500 || statusErr.Code == http.StatusTooManyRequests
+	}
+	if errors.Is(err, errFeedTooLarge) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	if droppedConnection(err) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// droppedConnection reports whether err means the server went away part way
+// through: the body ended early, or the connection was reset or closed
+// before a response arrived.
+func droppedConnection(err error) bool {
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return true
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	// HTTP/2 and TLS report resets as plain strings rather than errnos.
+	return strings.Contains(err.Error(), "connection reset by peer")
+}
+
+// backoff is the pause before retry number attempt+1: 500ms doubling per
+// attempt, capped at 10s.
+func backoff(attempt int) time.Duration {
+	wait := 500 * time.Millisecond << attempt
+	if wait <= 0 || wait > 10*time.Second {
+		return 10 * time.Second
+	}
+	return wait
+}
+
+// jitterFraction is how far jitter may move a wait either way.
+const jitterFraction = 0.2
+
+// jitter spreads d randomly over ±jitterFraction, so instances started
+// together by cron don't retry in lockstep. math/rand/v2 seeds itself per
+// process.
+func jitter(d time.Duration) time.Duration {
+	return time.Duration(float64(d) * (1 + jitterFraction*(2*rand.Float64()-1)))
+}
+
+var errFeedTooLarge = errors.New("feed is larger than max-feed-size")
+
+// readLimited reads all of r but fails once more than maxSize bytes arrive,
+// so a broken or hostile server can't exhaust memory. A maxSize of zero or
+// less disables the limit.
+func readLimited(r io.Reader, maxSize int64) ([]byte, error) {
+	if maxSize <= 0 {
+		return io.ReadAll(r)
+	}
+	body, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > maxSize {
+		return nil, fmt.Errorf("%w (%s)", errFeedTooLarge, formatByteSize(maxSize))
+	}
+	return body, nil
+}
+
+// parseByteSize parses sizes such as "512", "64KB", "8MB" or "1GB". Units
+// are binary (1KB = 1024 bytes) and case-insensitive.
+func parseByteSize(input string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(input))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(s, unit.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix))
+			multiplier = unit.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", input)
+	}
+	if n > math.MaxInt64/multiplier {
+		return 0, fmt.Errorf("size %q is too large", input)
+	}
+	return n * multiplier, nil
+}
+
+func formatByteSize(n int64) string {
+	switch {
+	case n >= 1<<30 && n%(1<<30) == 0:
+		return strconv.FormatInt(n>>30, 10) + "GB"
+	case n >= 1<<20 && n%(1<<20) == 0:
+		return strconv.FormatInt(n>>20, 10) + "MB"
+	case n >= 1<<10 && n%(1<<10) == 0:
+		return strconv.FormatInt(n>>10, 10) + "KB"
+	}
+	return strconv.FormatInt(n, 10) + "B"
+}
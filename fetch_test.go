@@ -0,0 +1,646 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testConfig is the fetch configuration of a run with default flags,
+// caching in dir.
+func testConfig(dir string) Config {
+	return Config{
+		CacheDir:        dir,
+		Timeout:         defaultTimeout * time.Second,
+		MaxIdleConns:    defaultMaxIdleConns,
+		IdleConnTimeout: defaultIdleConnTimeout,
+		NoJitter:        true,
+		MaxPages:        defaultMaxPages,
+	}
+}
+
+// readFixture returns the body of a feed in testdata.
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// silence turns warnings off for the rest of the test.
+func silence(tb testing.TB) {
+	tb.Helper()
+	old := quiet
+	quiet = true
+	tb.Cleanup(func() { quiet = old })
+}
+
+func TestStaleFallbackWithoutTTL(t *testing.T) {
+	silence(t)
+	good := readFixture(t, "timeline.rss")
+	var broken atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if broken.Load() {
+			w.Write([]byte("<html>502 Bad Gateway</html>"))
+			return
+		}
+		w.Write(good)
+	}))
+	defer srv.Close()
+
+	cfg := testConfig(t.TempDir())
+	items, err := loadItems(newFetcher(cfg), srv.URL, testNormalizeOptions(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	broken.Store(true)
+	stale, err := loadItems(newFetcher(cfg), srv.URL, testNormalizeOptions(t))
+	if err != nil {
+		t.Fatalf("garbage body with a good cached copy: %v", err)
+	}
+	if len(stale) != len(items) || stale[0].GUID != items[0].GUID {
+		t.Errorf("fallback items = %d starting %q, want the %d cached ones", len(stale), stale[0].GUID, len(items))
+	}
+
+	cfg.NoStaleFallback = true
+	if _, err := loadItems(newFetcher(cfg), srv.URL, testNormalizeOptions(t)); exitCode(err) != exitParseError {
+		t.Errorf("-no-stale-fallback: err = %v, want a parse error", err)
+	}
+}
+
+func TestMaxFeedSize(t *testing.T) {
+	silence(t)
+	body := readFixture(t, "timeline.rss")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	cfg := testConfig(t.TempDir())
+	cfg.NoStaleFallback = true
+	cfg.MaxFeedSize = int64(len(body)) - 1
+	_, err := loadItems(newFetcher(cfg), srv.URL, testNormalizeOptions(t))
+	if !errors.Is(err, errFeedTooLarge) {
+		t.Errorf("body one byte over the limit: err = %v, want %v", err, errFeedTooLarge)
+	}
+
+	cfg.MaxFeedSize = int64(len(body))
+	if _, err := loadItems(newFetcher(cfg), srv.URL, testNormalizeOptions(t)); err != nil {
+		t.Errorf("body exactly at the limit: %v", err)
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	for input, want := range map[string]int64{"512": 512, "64KB": 64 << 10, "8mb": 8 << 20, "1 GB": 1 << 30, "0": 0} {
+		if got, err := parseByteSize(input); err != nil || got != want {
+			t.Errorf("parseByteSize(%q) = %d, %v; want %d", input, got, err, want)
+		}
+	}
+	for _, input := range []string{"", "-1", "8TB", "9999999999GB"} {
+		if _, err := parseByteSize(input); err == nil {
+			t.Errorf("parseByteSize(%q): no error", input)
+		}
+	}
+}
+
+func TestWatchCyclesReuseConnection(t *testing.T) {
+	silence(t)
+	body := readFixture(t, "timeline.rss")
+	var conns atomic.Int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	}))
+	srv.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	cfg := testConfig(t.TempDir())
+	cfg.Feeds = []string{srv.URL}
+	cfg.FeedFormat = "auto"
+	f := newFetcher(cfg)
+	for cycle := 0; cycle < 3; cycle++ {
+		if _, err := loadFeeds(f, cfg); err != nil {
+			t.Fatalf("cycle %d: %v", cycle, err)
+		}
+	}
+	if n := conns.Load(); n != 1 {
+		t.Errorf("three cycles opened %d connections, want 1", n)
+	}
+}
+
+func TestNotModifiedRefreshesTTL(t *testing.T) {
+	silence(t)
+	body := readFixture(t, "timeline.rss")
+	var requests, notModified atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	cfg := testConfig(t.TempDir())
+	cfg.CacheTTL = time.Hour
+	f := newFetcher(cfg)
+	items, err := loadItems(f, srv.URL, testNormalizeOptions(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Age the cached copy past its TTL so the next run revalidates.
+	meta, _, err := f.cache.load(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta.FetchedAt = time.Now().Add(-2 * time.Hour)
+	if err := f.cache.touch(srv.URL, *meta); err != nil {
+		t.Fatal(err)
+	}
+
+	revalidated, err := loadItems(newFetcher(cfg), srv.URL, testNormalizeOptions(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if notModified.Load() != 1 {
+		t.Fatalf("expired copy: %d conditional 304s, want 1", notModified.Load())
+	}
+	if len(revalidated) != len(items) || revalidated[0].GUID != items[0].GUID {
+		t.Errorf("304 items = %d, want the %d cached ones", len(revalidated), len(items))
+	}
+	meta, _, err = f.cache.load(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(meta.FetchedAt) > time.Minute {
+		t.Errorf("304 left fetchedAt at %v, want it refreshed", meta.FetchedAt)
+	}
+
+	if _, err := loadItems(newFetcher(cfg), srv.URL, testNormalizeOptions(t)); err != nil {
+		t.Fatal(err)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("requests = %d, want 2: the refreshed TTL should serve the third run from cache", n)
+	}
+}
+
+// slowHandler serves body, stalling the first slow requests past any
+// short attempt timeout.
+func slowHandler(body []byte, slow int32, requests *atomic.Int32) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= slow {
+			select {
+			case <-time.After(2 * time.Second):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		w.Write(body)
+	}
+}
+
+func TestAttemptTimeoutRetriesSlowResponse(t *testing.T) {
+	silence(t)
+	var requests atomic.Int32
+	srv := httptest.NewServer(slowHandler(readFixture(t, "timeline.rss"), 1, &requests))
+	defer srv.Close()
+
+	cfg := testConfig(t.TempDir())
+	cfg.AttemptTimeout = 100 * time.Millisecond
+	cfg.Retries = 2
+	if _, err := loadItems(newFetcher(cfg), srv.URL, testNormalizeOptions(t)); err != nil {
+		t.Fatalf("slow first attempt, fast second: %v", err)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("requests = %d, want 2", n)
+	}
+}
+
+func TestDeadlineBoundsSlowAttempts(t *testing.T) {
+	silence(t)
+	var requests atomic.Int32
+	srv := httptest.NewServer(slowHandler(readFixture(t, "timeline.rss"), 100, &requests))
+	defer srv.Close()
+
+	cfg := testConfig(t.TempDir())
+	cfg.NoStaleFallback = true
+	cfg.AttemptTimeout = 100 * time.Millisecond
+	cfg.Deadline = 300 * time.Millisecond
+	cfg.Retries = 5
+	start := time.Now()
+	_, err := loadItems(newFetcher(cfg), srv.URL, testNormalizeOptions(t))
+	var fetchErr *FetchError
+	if !errors.As(err, &fetchErr) || !fetchErr.Timeout() {
+		t.Errorf("every attempt slow: err = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("gave up after %v, want within the 300ms deadline and one backoff", elapsed)
+	}
+	if n := requests.Load(); n > 2 {
+		t.Errorf("requests = %d, want the deadline to stop retries early", n)
+	}
+}
+
+func TestRetryAfterDroppedBody(t *testing.T) {
+	silence(t)
+	body := readFixture(t, "timeline.rss")
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) > 1 {
+			w.Write(body)
+			return
+		}
+		// Promise the whole body, send half of it and hang up.
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		fmt.Fprintf(buf, "HTTP/1.1 200 OK\r\nContent-Length: %d\r\n\r\n", len(body))
+		buf.Write(body[:len(body)/2])
+		buf.Flush()
+	}))
+	defer srv.Close()
+
+	cfg := testConfig(t.TempDir())
+	cfg.Retries = 1
+	items, err := loadItems(newFetcher(cfg), srv.URL, testNormalizeOptions(t))
+	if err != nil {
+		t.Fatalf("dropped first body, good second: %v", err)
+	}
+	if len(items) != 5 || requests.Load() != 2 {
+		t.Errorf("got %d items in %d requests, want 5 in 2", len(items), requests.Load())
+	}
+
+	requests.Store(0)
+	cfg.Retries = 0
+	cfg.NoStaleFallback = true
+	_, err = loadItems(newFetcher(cfg), srv.URL, testNormalizeOptions(t))
+	if !droppedConnection(err) {
+		t.Errorf("no retries: err = %v, want a dropped connection", err)
+	}
+}
+
+func TestJitterBounds(t *testing.T) {
+	for attempt := 0; attempt < 6; attempt++ {
+		base := backoff(attempt)
+		lo := time.Duration(float64(base) * (1 - jitterFraction))
+		hi := time.Duration(float64(base) * (1 + jitterFraction))
+		seen := make(map[time.Duration]bool)
+		for i := 0; i < 1000; i++ {
+			d := jitter(base)
+			if d < lo || d > hi {
+				t.Fatalf("jitter(%v) = %v, outside [%v, %v]", base, d, lo, hi)
+			}
+			seen[d] = true
+		}
+		if len(seen) < 2 {
+			t.Errorf("jitter(%v) never varied", base)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	want := []time.Duration{500 * time.Millisecond, time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for attempt, w := range want {
+		if got := backoff(attempt); got != w {
+			t.Errorf("backoff(%d) = %v, want %v", attempt, got, w)
+		}
+	}
+	if got := backoff(80); got != 10*time.Second {
+		t.Errorf("backoff(80) = %v, want the 10s cap", got)
+	}
+}
+
+func TestVerboseSummary(t *testing.T) {
+	silence(t)
+	body := readFixture(t, "timeline.rss")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	cfg := testConfig(t.TempDir())
+	cfg.CacheTTL = time.Hour
+	f := newFetcher(cfg)
+	for range 2 {
+		if _, err := loadItems(f, srv.URL, testNormalizeOptions(t)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := f.stats
+	if s.cacheMisses != 1 || s.cacheHits != 1 {
+		t.Errorf("cache %d hit/%d miss, want 1/1", s.cacheHits, s.cacheMisses)
+	}
+	if s.bytes != int64(len(body)) {
+		t.Errorf("bytes = %d, want %d: only the miss downloads", s.bytes, len(body))
+	}
+	if s.rawItems != 10 {
+		t.Errorf("raw items = %d, want 10 over two runs", s.rawItems)
+	}
+	if s.fetchTime <= 0 || s.parseTime <= 0 {
+		t.Errorf("fetch %v, parse %v; want both timed", s.fetchTime, s.parseTime)
+	}
+
+	s.fetchTime, s.parseTime = 1500*time.Microsecond, 250*time.Microsecond
+	s.bytes = 3482
+	want := "fetch 1.5ms, parse 250µs, 3.4 KiB downloaded, cache 1 hit/1 miss; items 10 raw, 4 after filters, 3 shown"
+	if got := s.summary(4, 3); got != want {
+		t.Errorf("summary =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestHeaderTimeout(t *testing.T) {
+	silence(t)
+	body := readFixture(t, "timeline.rss")
+	slowHeaders := httptest.NewServer(slowHandler(body, 100, new(atomic.Int32)))
+	defer slowHeaders.Close()
+	slowBody := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		half := len(body) / 2
+		w.Write(body[:half])
+		w.(http.Flusher).Flush()
+		time.Sleep(300 * time.Millisecond)
+		w.Write(body[half:])
+	}))
+	defer slowBody.Close()
+
+	cfg := testConfig(t.TempDir())
+	cfg.NoStaleFallback = true
+	cfg.HeaderTimeout = 100 * time.Millisecond
+	start := time.Now()
+	_, err := loadItems(newFetcher(cfg), slowHeaders.URL, testNormalizeOptions(t))
+	var fetchErr *FetchError
+	if !errors.As(err, &fetchErr) || !fetchErr.Timeout() {
+		t.Errorf("headers delayed: err = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("headers delayed: gave up after %v, want soon after the 100ms header timeout", elapsed)
+	}
+
+	items, err := loadItems(newFetcher(cfg), slowBody.URL, testNormalizeOptions(t))
+	if err != nil || len(items) != 5 {
+		t.Errorf("body delayed past the header timeout: %d items, %v; want all 5", len(items), err)
+	}
+}
+
+// largeFeed is an RSS feed of n releases, one an hour back from testNow.
+func largeFeed(n int) []byte {
+	var b strings.Builder
+	b.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<rss version=\"2.0\">\n<channel>\n<title>Large</title>\n")
+	for i := range n {
+		fmt.Fprintf(&b, "<item>\n<title>iOS 17.%d (21B%d) for iPhone 15, iPhone 15 Pro has been released</title>\n", i%10, i)
+		fmt.Fprintf(&b, "<guid>ios-%d</guid>\n<pubDate>%s</pubDate>\n", i, testNow.Add(-time.Duration(i)*time.Hour).Format(time.RFC1123Z))
+		b.WriteString("<description>Fixes CVE-2023-42846.</description>\n</item>\n")
+	}
+	b.WriteString("</channel>\n</rss>\n")
+	return []byte(b.String())
+}
+
+// parsedServer serves body with a cache TTL long enough that every load
+// after the first reads it from the cache in dir.
+func parsedServer(tb testing.TB, body []byte, dir string, cacheParsed bool) (*httptest.Server, Config) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	}))
+	tb.Cleanup(srv.Close)
+	cfg := testConfig(dir)
+	cfg.CacheTTL = time.Hour
+	cfg.CacheParsed = cacheParsed
+	return srv, cfg
+}
+
+func TestCacheParsed(t *testing.T) {
+	norm := testNormalizeOptions(t)
+	srv, cfg := parsedServer(t, largeFeed(50), t.TempDir(), true)
+	cold := newFetcher(cfg)
+	want, err := loadItems(cold, srv.URL, norm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	warm := newFetcher(cfg)
+	got, err := loadItems(warm, srv.URL, norm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cold.stats.parseTime == 0 || warm.stats.parseTime != 0 {
+		t.Errorf("parse time cold %v, warm %v; want only the cold run to parse", cold.stats.parseTime, warm.stats.parseTime)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("warm run gave %d items, want %d", len(got), len(want))
+	}
+	for i := range got {
+		// Saved dates come back with a fixed zone, so compare instants.
+		if !got[i].PubDate.Equal(want[i].PubDate) {
+			t.Errorf("item %d: saved date %v, want %v", i, got[i].PubDate, want[i].PubDate)
+		}
+		got[i].PubDate = want[i].PubDate
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("warm run items differ from the ones the cold run normalized:\n%+v\nwant\n%+v", got[0], want[0])
+	}
+
+	norm.MaxTitleLen = 20
+	if trimmed, err := loadItems(newFetcher(cfg), srv.URL, norm); err != nil || trimmed[0].DeviceOrNotes == want[0].DeviceOrNotes {
+		t.Errorf("changed options reused the saved items: device %q, %v", trimmed[0].DeviceOrNotes, err)
+	}
+}
+
+// BenchmarkLoadParsed compares loading a cached 1000-item feed that has to
+// be parsed again with one whose items -cache-parsed saved.
+func BenchmarkLoadParsed(b *testing.B) {
+	body := largeFeed(1000)
+	for _, bm := range []struct {
+		name        string
+		cacheParsed bool
+	}{{"cold", false}, {"warm", true}} {
+		b.Run(bm.name, func(b *testing.B) {
+			silence(b)
+			norm := testNormalizeOptions(b)
+			srv, cfg := parsedServer(b, body, b.TempDir(), bm.cacheParsed)
+			if _, err := loadItems(newFetcher(cfg), srv.URL, norm); err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			for range b.N {
+				if _, err := loadItems(newFetcher(cfg), srv.URL, norm); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestOffline(t *testing.T) {
+	silence(t)
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write(readFixture(t, "timeline.rss"))
+	}))
+	defer srv.Close()
+
+	cfg := testConfig(t.TempDir())
+	cfg.Offline = true
+	cfg.Retries = 2
+	_, err := loadItems(newFetcher(cfg), srv.URL, testNormalizeOptions(t))
+	if !errors.Is(err, errOffline) || !strings.Contains(err.Error(), srv.URL) {
+		t.Errorf("offline with no cache: err = %v, want errOffline naming %s", err, srv.URL)
+	}
+	if n := requests.Load(); n != 0 {
+		t.Errorf("offline with no cache: %d requests, want none", n)
+	}
+
+	online := cfg
+	online.Offline = false
+	if _, err := loadItems(newFetcher(online), srv.URL, testNormalizeOptions(t)); err != nil {
+		t.Fatal(err)
+	}
+	items, err := loadItems(newFetcher(cfg), srv.URL, testNormalizeOptions(t))
+	if err != nil || len(items) != 5 {
+		t.Errorf("offline with a cached copy: %d items, %v; want all 5", len(items), err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("%d requests, want only the online one", n)
+	}
+}
+
+func TestDumpRaw(t *testing.T) {
+	silence(t)
+	// A byte order mark and CRLF line endings, which re-encoding would lose.
+	body := append([]byte("\xef\xbb\xbf"), strings.ReplaceAll(string(readFixture(t, "timeline.rss")), "\n", "\r\n")...)
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	}))
+	defer good.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "upstream down", http.StatusBadGateway)
+	}))
+	defer broken.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	dir := t.TempDir()
+	cfg := testConfig(t.TempDir())
+	cfg.NoStaleFallback = true
+	cfg.DumpRaw = filepath.Join(dir, "feed.xml")
+	cfg.Feeds = []string{good.URL, broken.URL, down.URL}
+	previous := filepath.Join(dir, "feed.2.xml")
+	if err := os.WriteFile(previous, []byte("last good dump"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	f := newFetcher(cfg)
+	if _, err := loadItems(f, good.URL, testNormalizeOptions(t)); err != nil {
+		t.Fatal(err)
+	}
+	if dumped, err := os.ReadFile(cfg.DumpRaw); err != nil || string(dumped) != string(body) {
+		t.Errorf("dump of %d bytes, %v; want the %d fetched bytes exactly", len(dumped), err, len(body))
+	}
+
+	if _, err := loadItems(f, broken.URL, testNormalizeOptions(t)); err == nil {
+		t.Fatal("502 response: no error")
+	}
+	if kept, err := os.ReadFile(previous); err != nil || string(kept) != "last good dump" {
+		t.Errorf("failed fetch replaced the earlier dump: %q, %v", kept, err)
+	}
+	if _, err := loadItems(f, down.URL, testNormalizeOptions(t)); err == nil {
+		t.Fatal("closed server: no error")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "feed.3.xml")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("failed fetch wrote feed.3.xml: %v", err)
+	}
+}
+
+func TestFollowNextPages(t *testing.T) {
+	pages := map[string][]byte{
+		"/feed":    readFixture(t, "paged-1.rss"),
+		"/pages/2": readFixture(t, "paged-2.rss"),
+	}
+	var requests sync.Map
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := requests.LoadOrStore(r.URL.Path, new(atomic.Int32))
+		n.(*atomic.Int32).Add(1)
+		body, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(body)
+	}))
+	defer srv.Close()
+	count := func(path string) int32 {
+		n, ok := requests.Load(path)
+		if !ok {
+			return 0
+		}
+		return n.(*atomic.Int32).Load()
+	}
+	builds := func(items []Item) string {
+		var out []string
+		for _, it := range items {
+			out = append(out, it.Build)
+		}
+		return strings.Join(out, ",")
+	}
+
+	cfg := testConfig(t.TempDir())
+	cfg.FollowNext = true
+	// Page 2 links to itself: the walk stops there without a third fetch.
+	var items []Item
+	log := captureStderr(t, func() {
+		var err error
+		items, err = loadItems(newFetcher(cfg), srv.URL+"/feed", testNormalizeOptions(t))
+		if err != nil {
+			t.Error(err)
+		}
+	})
+	if got, want := builds(items), "21B91,23C5041e,21S71,21K69"; got != want {
+		t.Errorf("two pages: builds %s, want %s", got, want)
+	}
+	if n := count("/pages/2"); n != 1 {
+		t.Errorf("page 2 fetched %d times, want once despite linking to itself", n)
+	}
+	if !strings.Contains(log, "links back to a page already read") {
+		t.Errorf("self link: stderr %q, want a warning", log)
+	}
+
+	cfg.MaxPages = 1
+	log = captureStderr(t, func() {
+		var err error
+		items, err = loadItems(newFetcher(cfg), srv.URL+"/feed", testNormalizeOptions(t))
+		if err != nil {
+			t.Error(err)
+		}
+	})
+	if got, want := builds(items), "21B91,23C5041e"; got != want {
+		t.Errorf("-max-pages 1: builds %s, want %s", got, want)
+	}
+	if n := count("/pages/2"); n != 1 {
+		t.Errorf("-max-pages 1 fetched page 2")
+	}
+	if !strings.Contains(log, "more pages than -max-pages 1") {
+		t.Errorf("-max-pages 1: stderr %q, want a warning", log)
+	}
+}
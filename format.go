@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// renderPorcelain writes one tab-separated line per item with no header,
+// dividers or color. Columns are guid, pubdate (RFC 3339, UTC), platformkey,
+// version, build and device.
+//
+// The format is a stability contract for scripts: columns are never removed
+// or reordered, and new ones are only ever appended. Tabs and newlines inside
+// values are replaced by spaces so every record stays on a single line.
+func renderPorcelain(items []Item, out io.Writer) {
+	for _, it := range items {
+		fields := []string{
+			it.GUID,
+			it.PubDate.UTC().Format(time.RFC3339),
+			it.PlatformKey,
+			it.Version,
+			it.Build,
+			it.RawDevice,
+		}
+		for i, f := range fields {
+			fields[i] = porcelainField(f)
+		}
+		io.WriteString(out, strings.Join(fields, "\t")+"\n")
+	}
+}
+
+func porcelainField(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '\t', '\n', '\r':
+			return ' '
+		}
+		return r
+	}, s)
+}
+
+// jsonItem is the serialized form of an Item in every structured output:
+// -format json arrays and lines, latest by platform, diff and -output-dir.
+// Derived fields are computed once in toJSONItem so they all agree.
+type jsonItem struct {
+	Title             string   `json:"title"`
+	Link              string   `json:"link"`
+	PubDate           string   `json:"pubDate" format:"date-time"`
+	DateKnown         bool     `json:"dateKnown"`
+	GUID              string   `json:"guid"`
+	Description       string   `json:"description"`
+	PlatformKey       string   `json:"platformKey"`
+	PlatformLabel     string   `json:"platformLabel"`
+	Version           string   `json:"version"`
+	Build             string   `json:"build"`
+	Device            string   `json:"device"`
+	Devices           []string `json:"devices"`
+	DeviceCount       int      `json:"deviceCount"`
+	Notes             string   `json:"notes"`
+	PreRelease        bool     `json:"preRelease"`
+	PreReleaseStage   string   `json:"preReleaseStage" enum:",beta,rc"`
+	PreReleaseKeyword string   `json:"preReleaseKeyword"`
+	SecurityContent   bool     `json:"securityContent"`
+	Provenance        string   `json:"provenance" enum:"released,expected"`
+	Source            string   `json:"source"`
+}
+
+func toJSONItem(it Item) jsonItem {
+	return jsonItem{
+		Title:             it.Title,
+		Link:              it.Link,
+		PubDate:           it.PubDate.UTC().Format(time.RFC3339),
+		DateKnown:         dateKnown(it.PubDate),
+		GUID:              it.GUID,
+		Description:       it.Description,
+		PlatformKey:       it.PlatformKey,
+		PlatformLabel:     it.PlatformLabel,
+		Version:           it.Version,
+		Build:             it.Build,
+		Device:            it.RawDevice,
+		Devices:           it.Devices,
+		DeviceCount:       len(it.Devices),
+		Notes:             it.Notes,
+		PreRelease:        it.PreRelease,
+		PreReleaseStage:   it.PreReleaseStage,
+		PreReleaseKeyword: it.PreReleaseKeyword,
+		SecurityContent:   it.SecurityContent,
+		Provenance:        it.Provenance,
+		Source:            it.Source,
+	}
+}
+
+// jsonFeed is a Feed in -show-feed-info JSON output.
+type jsonFeed struct {
+	URL           string `json:"url"`
+	Title         string `json:"title"`
+	Description   string `json:"description"`
+	LastBuildDate string `json:"lastBuildDate,omitempty"`
+}
+
+// renderJSONWithFeeds writes {"feeds": [...], "items": ...}, with items as
+// renderJSON or, for latest, renderJSONByPlatform would write them.
+func renderJSONWithFeeds(items []Item, feeds []Feed, latest bool, out io.Writer) error {
+	doc := struct {
+		Feeds []jsonFeed `json:"feeds"`
+		Items any        `json:"items"`
+	}{Feeds: []jsonFeed{}}
+	for _, f := range feeds {
+		jf := jsonFeed{URL: f.URL, Title: f.Title, Description: f.Description}
+		if !f.LastBuildDate.IsZero() {
+			jf.LastBuildDate = f.LastBuildDate.UTC().Format(time.RFC3339)
+		}
+		doc.Feeds = append(doc.Feeds, jf)
+	}
+	if latest {
+		doc.Items = jsonByPlatform(items)
+	} else {
+		doc.Items = jsonItems(items)
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+func jsonItems(items []Item) []jsonItem {
+	list := make([]jsonItem, 0, len(items))
+	for _, it := range items {
+		list = append(list, toJSONItem(it))
+	}
+	return list
+}
+
+// jsonByPlatform keys items by platform, keeping the first item of each.
+func jsonByPlatform(items []Item) map[string]jsonItem {
+	byPlatform := make(map[string]jsonItem)
+	for _, it := range items {
+		key := it.PlatformKey
+		if key == "" {
+			key = "other"
+		}
+		if _, ok := byPlatform[key]; !ok {
+			byPlatform[key] = toJSONItem(it)
+		}
+	}
+	return byPlatform
+}
+
+// renderJSONByPlatform writes the output of latest -format json: one object
+// keyed by platform, such as {"ios": {...}, "macos": {...}}, holding only
+// the platforms that made it through the filters.
+func renderJSONByPlatform(items []Item, out io.Writer) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(jsonByPlatform(items))
+}
+
+// renderFeedInfo prints a line per feed above the table: its title, or the
+// URL for untitled feeds, and when it was last built.
+func renderFeedInfo(feeds []Feed, opts renderOptions, out io.Writer) {
+	color := colorizer{enabled: opts.Color}
+	for _, f := range feeds {
+		title := f.Title
+		if title == "" {
+			title = f.URL
+		}
+		line := strings.Repeat(" ", opts.Indent) + color.wrap("1", title)
+		if !f.LastBuildDate.IsZero() {
+			line += color.dim(" (built " + f.LastBuildDate.UTC().Format("2006-01-02 15:04 UTC") + ")")
+		}
+		fmt.Fprintln(out, line)
+	}
+	if len(feeds) > 0 {
+		fmt.Fprintln(out)
+	}
+}
+
+// renderPlatformSummary prints one line per item, as selectItems leaves
+// them for -platform-summary: the platform stripe and label in its color,
+// then the version and build.
+func renderPlatformSummary(items []Item, opts renderOptions, out io.Writer) {
+	color := colorizer{enabled: opts.Color}
+	labelWidth := 0
+	for _, it := range items {
+		labelWidth = max(labelWidth, displayWidth(platformLabelForKey(summaryKey(it))))
+	}
+	for _, it := range items {
+		key := summaryKey(it)
+		code := platformColor(key)
+		label := color.color(code, pad(platformLabelForKey(key), labelWidth))
+		version := buildVersion(normalizeVersion(it.Version, opts.NormalizeVersion), it.Build, opts.BuildFirst)
+		fmt.Fprintf(out, "%s%s %s  %s\n", strings.Repeat(" ", opts.Indent), color.color(code, stripeChar(key, opts.ASCII)), label, color.wrap("1", version))
+	}
+}
+
+func summaryKey(it Item) string {
+	if it.PlatformKey == "" {
+		return "other"
+	}
+	return it.PlatformKey
+}
+
+// renderJSON writes items as an indented JSON array. Items are encoded
+// one at a time as the array is written, so a large merged feed is never
+// held in memory as JSON all at once.
+func renderJSON(items []Item, out io.Writer) error {
+	if len(items) == 0 {
+		_, err := io.WriteString(out, "[]\n")
+		return err
+	}
+	w := bufio.NewWriter(out)
+	w.WriteString("[\n")
+	for i, it := range items {
+		raw, err := json.MarshalIndent(toJSONItem(it), "  ", "  ")
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			w.WriteString(",\n")
+		}
+		w.WriteString("  ")
+		if _, err := w.Write(raw); err != nil {
+			return err
+		}
+	}
+	w.WriteString("\n]\n")
+	return w.Flush()
+}
+
+// renderJSONLines writes each item as a compact JSON object on its own line
+// (-json-array=false), for tools that read a stream one record at a time.
+// latest writes its one item per platform the same way.
+func renderJSONLines(items []Item, out io.Writer) error {
+	enc := json.NewEncoder(out)
+	for _, it := range items {
+		if err := enc.Encode(toJSONItem(it)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderBadge prints the newest version of a single platform, e.g.
+// "iOS 17.1", for embedding in badges and docs. It fails when the items span
+// more than one platform or when there is nothing to show.
+func renderBadge(items []Item, showBuild, buildFirst bool, out io.Writer) error {
+	if len(items) == 0 {
+		return errors.New("no matching release")
+	}
+
+	newest := items[0]
+	for _, it := range items[1:] {
+		if it.PlatformKey != newest.PlatformKey {
+			return fmt.Errorf("badge needs a single platform, found %s and %s: use -platform", newest.PlatformKey, it.PlatformKey)
+		}
+		if it.PubDate.After(newest.PubDate) {
+			newest = it
+		}
+	}
+
+	version := newest.Version
+	if showBuild {
+		version = buildVersion(newest.Version, newest.Build, buildFirst)
+	}
+	_, err := fmt.Fprintln(out, strings.TrimSpace(newest.PlatformLabel+" "+version))
+	return err
+}
+
+// renderEnv prints shell assignments for the newest item of each platform,
+// such as IPSW_IOS_VERSION=17.1, for sourcing in scripts. Platforms appear
+// in the order of their newest item; values that aren't plainly safe are
+// single-quoted.
+func renderEnv(items []Item, out io.Writer) error {
+	seen := make(map[string]bool)
+	for _, it := range items {
+		key := it.PlatformKey
+		if key == "" {
+			key = "other"
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		prefix := "IPSW_" + envName(key) + "_"
+		for _, v := range [][2]string{
+			{"VERSION", it.Version},
+			{"BUILD", it.Build},
+			{"DATE", it.PubDate.UTC().Format(time.RFC3339)},
+		} {
+			if _, err := fmt.Fprintf(out, "%s%s=%s\n", prefix, v[0], shellQuote(v[1])); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// envName upper-cases s and replaces anything that can't appear in a shell
+// variable name with an underscore.
+func envName(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// shellQuote leaves values made of safe characters bare and single-quotes
+// everything else, so eval and source never expand or split them.
+func shellQuote(s string) string {
+	safe := s != "" && !strings.ContainsFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("._-+:/,@%", r))
+	})
+	if safe {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderPorcelain(t *testing.T) {
+	items := []Item{
+		{
+			GUID:        "ios-21B91",
+			PubDate:     time.Date(2023, 11, 7, 18, 0, 0, 0, time.FixedZone("PST", -8*3600)),
+			PlatformKey: "ios",
+			Version:     "17.1.1",
+			Build:       "21B91",
+			RawDevice:   "iPhone 15,\tiPhone 15 Pro",
+		},
+		{
+			GUID:        "guid\twith\ttabs",
+			PubDate:     time.Date(2023, 10, 25, 17, 0, 0, 0, time.UTC),
+			PlatformKey: "macos",
+			Version:     "14.1\n",
+			Build:       "23B\r\n74",
+		},
+	}
+	var b strings.Builder
+	renderPorcelain(items, &b)
+
+	want := "ios-21B91\t2023-11-08T02:00:00Z\tios\t17.1.1\t21B91\tiPhone 15, iPhone 15 Pro\n" +
+		"guid with tabs\t2023-10-25T17:00:00Z\tmacos\t14.1 \t23B  74\t\n"
+	if got := b.String(); got != want {
+		t.Errorf("renderPorcelain =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestPreserveWhitespace(t *testing.T) {
+	raw := rawItem{
+		Title:       "iOS 17.1.1 (21B91) has been released",
+		PubDate:     "Tue, 07 Nov 2023 18:00:00 +0000",
+		Description: "<p>iOS 17.1.1 has been released &amp; fixes:</p>\n<ul>\n  <li>Wireless   charging</li>\n  <li>Weather widget</li>\n</ul>\n",
+	}
+	tests := []struct {
+		preserve bool
+		want     string
+	}{
+		{false, "iOS 17.1.1 has been released & fixes: Wireless charging Weather widget"},
+		{true, "iOS 17.1.1 has been released & fixes:\n\n  Wireless   charging\n  Weather widget"},
+	}
+	for _, tt := range tests {
+		opts := testNormalizeOptions(t)
+		opts.PreserveWhitespace = tt.preserve
+		it := normalizeItem(raw, opts)
+
+		var b strings.Builder
+		if err := renderJSONLines([]Item{it}, &b); err != nil {
+			t.Fatal(err)
+		}
+		var got jsonItem
+		if err := json.Unmarshal([]byte(b.String()), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Description != tt.want {
+			t.Errorf("preserve %t: description %q, want %q", tt.preserve, got.Description, tt.want)
+		}
+		if it.DeviceOrNotes != "& fixes: Wireless charging Weather widget" {
+			t.Errorf("preserve %t: table notes %q, want them collapsed", tt.preserve, it.DeviceOrNotes)
+		}
+	}
+}
+
+func TestJSONFeedFixture(t *testing.T) {
+	got := loadFixture(t, "timeline.json")
+	want := loadFixture(t, "timeline.rss")[:3]
+	if len(got) != len(want) {
+		t.Fatalf("JSON Feed gave %d items, want %d", len(got), len(want))
+	}
+	for i := range want {
+		g, w := toJSONItem(got[i]), toJSONItem(want[i])
+		if !reflect.DeepEqual(g, w) {
+			t.Errorf("item %d from JSON Feed:\n%+v\nwant the RSS equivalent:\n%+v", i, g, w)
+		}
+	}
+
+	page, err := parseFeedPage(readFixture(t, "timeline.json"), "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.feed.Title != "IPSW Downloads Timeline" {
+		t.Errorf("feed title = %q", page.feed.Title)
+	}
+	if _, err := parseFeed(readFixture(t, "timeline.rss"), "json"); exitCode(err) != exitParseError {
+		t.Errorf("RSS read as -feed-format json: err = %v, want a parse error", err)
+	}
+}
+
+func TestRenderEnv(t *testing.T) {
+	var b strings.Builder
+	if err := renderEnv(loadFixture(t, "timeline.rss"), &b); err != nil {
+		t.Fatal(err)
+	}
+	want := `IPSW_IOS_VERSION=17.1.1
+IPSW_IOS_BUILD=21B91
+IPSW_IOS_DATE=2023-11-07T18:00:00Z
+IPSW_MACOS_VERSION='14.2 beta 2'
+IPSW_MACOS_BUILD=23C5041e
+IPSW_MACOS_DATE=2023-11-07T17:00:00Z
+IPSW_WATCHOS_VERSION=10.1
+IPSW_WATCHOS_BUILD=21S71
+IPSW_WATCHOS_DATE=2023-10-25T17:00:00Z
+IPSW_IPADOS_VERSION=17.1
+IPSW_IPADOS_BUILD=21B74
+IPSW_IPADOS_DATE=2023-10-25T17:00:00Z
+IPSW_TVOS_VERSION=17.1
+IPSW_TVOS_BUILD=21K69
+IPSW_TVOS_DATE=2023-10-24T17:00:00Z
+`
+	if b.String() != want {
+		t.Errorf("renderEnv =\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestEnvNameAndShellQuote(t *testing.T) {
+	for in, want := range map[string]string{"ios": "IOS", "vision-os": "VISION_OS", "home pod": "HOME_POD", "bridgeOS2": "BRIDGEOS2"} {
+		if got := envName(in); got != want {
+			t.Errorf("envName(%q) = %q, want %q", in, got, want)
+		}
+	}
+	for in, want := range map[string]string{
+		"17.1.1":            "17.1.1",
+		"https://ipsw.me/a": "https://ipsw.me/a",
+		"":                  "''",
+		"14.2 beta 2":       "'14.2 beta 2'",
+		"$(rm -rf /)":       "'$(rm -rf /)'",
+		"it's":              `'it'\''s'`,
+		"a;b":               "'a;b'",
+		"`date`":            "'`date`'",
+		"line\nbreak":       "'line\nbreak'",
+	} {
+		if got := shellQuote(in); got != want {
+			t.Errorf("shellQuote(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestParseChannel(t *testing.T) {
+	page, err := parseFeedPage(readFixture(t, "timeline.rss"), "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Feed{
+		Title:         "IPSW Downloads Timeline",
+		Description:   "The latest firmware releases",
+		LastBuildDate: time.Date(2023, 11, 8, 18, 0, 0, 0, time.UTC),
+	}
+	if page.feed.Title != want.Title || page.feed.Description != want.Description || !page.feed.LastBuildDate.Equal(want.LastBuildDate) {
+		t.Errorf("feed = %+v, want %+v", page.feed, want)
+	}
+
+	page, err = parseFeedPage([]byte(`<rss version="2.0"><channel>
+<title>  IPSW &amp;
+  Friends </title>
+<description><![CDATA[<b>Firmware</b> &lt;news&gt;]]></description>
+<lastBuildDate>sometime last week</lastBuildDate>
+</channel></rss>`), "rss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.feed.Title != "IPSW & Friends" || page.feed.Description != "Firmware <news>" {
+		t.Errorf("title %q, description %q", page.feed.Title, page.feed.Description)
+	}
+	if !page.feed.LastBuildDate.IsZero() {
+		t.Errorf("unreadable lastBuildDate parsed as %v", page.feed.LastBuildDate)
+	}
+}
+
+func TestPlatformSummary(t *testing.T) {
+	feed := "file://" + filepath.Join("testdata", "timeline.rss")
+	out := runListArgs(t, "-platform-summary", "-f", feed)
+	checkGolden(t, "platform-summary", out)
+
+	if withBetas := runListArgs(t, "-platform-summary", "-include-prerelease", "-f", feed); !strings.Contains(withBetas, "14.2 beta 2 (23C5041e)") {
+		t.Errorf("-include-prerelease should count the macOS beta:\n%s", withBetas)
+	}
+}
+
+func TestStreamedJSONIsValid(t *testing.T) {
+	raw, err := parseFeed(largeFeed(2000), "rss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var items []Item
+	for i, r := range raw {
+		if i%3 == 0 {
+			r.Description = "Quotes \"here\", <tags> & \u2028line separators, tabs\tand\nnewlines"
+		}
+		items = append(items, normalizeItem(r, testNormalizeOptions(t)))
+	}
+
+	for _, array := range []bool{true, false} {
+		var b strings.Builder
+		if err := renderItems(items, nil, Config{Format: "json", JSONArray: array}, &b); err != nil {
+			t.Fatal(err)
+		}
+		out := b.String()
+		var decoded []map[string]any
+		if array {
+			if !json.Valid([]byte(out)) {
+				t.Fatalf("-json-array: output is not valid JSON")
+			}
+			if err := json.Unmarshal([]byte(out), &decoded); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			for i, line := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+				var obj map[string]any
+				if err := json.Unmarshal([]byte(line), &obj); err != nil {
+					t.Fatalf("-json-array=false: line %d is not a JSON object: %v", i+1, err)
+				}
+				decoded = append(decoded, obj)
+			}
+		}
+		if len(decoded) != len(items) {
+			t.Errorf("-json-array=%t: decoded %d items, want %d", array, len(decoded), len(items))
+		}
+		if last := decoded[len(decoded)-1]; last["guid"] != "ios-1999" {
+			t.Errorf("-json-array=%t: last item %v, want guid ios-1999", array, last)
+		}
+	}
+
+	var empty strings.Builder
+	if err := renderJSON(nil, &empty); err != nil || empty.String() != "[]\n" {
+		t.Errorf("no items: %q, %v; want an empty array", empty.String(), err)
+	}
+}
+
+func TestJSONKeysAgreeAcrossFormats(t *testing.T) {
+	items := loadFixture(t, "timeline.rss")[:1]
+	keysOf := func(obj map[string]any) []string {
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return keys
+	}
+	render := func(fn func(io.Writer) error) string {
+		var b strings.Builder
+		if err := fn(&b); err != nil {
+			t.Fatal(err)
+		}
+		return b.String()
+	}
+	decode := func(out string, into any) {
+		if err := json.Unmarshal([]byte(out), into); err != nil {
+			t.Fatalf("%v in %s", err, out)
+		}
+	}
+
+	objects := map[string]map[string]any{}
+	var array []map[string]any
+	decode(render(func(w io.Writer) error { return renderJSON(items, w) }), &array)
+	objects["array"] = array[0]
+	var line map[string]any
+	decode(render(func(w io.Writer) error { return renderJSONLines(items, w) }), &line)
+	objects["lines"] = line
+	var byPlatform map[string]map[string]any
+	decode(render(func(w io.Writer) error { return renderJSONByPlatform(items, w) }), &byPlatform)
+	objects["latest"] = byPlatform["ios"]
+	var withFeeds struct{ Items []map[string]any }
+	decode(render(func(w io.Writer) error { return renderJSONWithFeeds(items, nil, false, w) }), &withFeeds)
+	objects["feed info"] = withFeeds.Items[0]
+	var diff struct{ Added []map[string]any }
+	decode(render(func(w io.Writer) error { return renderDiff(items, nil, Config{Format: "json"}, w) }), &diff)
+	objects["diff"] = diff.Added[0]
+
+	properties := itemSchema()["properties"].(map[string]any)
+	want := keysOf(properties)
+	for _, key := range []string{"preRelease", "securityContent", "dateKnown", "source", "platformKey", "platformLabel"} {
+		if _, ok := properties[key]; !ok {
+			t.Errorf("schema lacks %q", key)
+		}
+	}
+	for name, obj := range objects {
+		if got := keysOf(obj); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s keys:\n%q\nwant the schema's:\n%q", name, got, want)
+		}
+	}
+}
+
+func TestNamespacedElements(t *testing.T) {
+	raw, err := parseFeed(readFixture(t, "namespaced.rss"), "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The first item has <title> after dc:title, a rel="alternate"
+	// atom:link and only dc:date; the second only dc:title, a rel="self"
+	// atom:link and both dates.
+	want := []rawItem{
+		{
+			Title:   "iOS 17.1.1 (21B91) for iPhone 15, iPhone 15 Pro has been released",
+			Link:    "https://ipsw.me/iOS/17.1.1",
+			PubDate: "2023-11-07T18:00:00Z",
+			GUID:    "ios-21B91",
+		},
+		{
+			Title:   "macOS 14.2 beta 2 (23C5041e) has been released",
+			Link:    "https://ipsw.me/macOS/14.2b2",
+			PubDate: "Tue, 07 Nov 2023 17:00:00 +0000",
+			GUID:    "macos-23C5041e",
+		},
+	}
+	if !reflect.DeepEqual(raw, want) {
+		t.Errorf("parsed\n%+v\nwant\n%+v", raw, want)
+	}
+	if it := loadFixture(t, "namespaced.rss")[0]; !it.PubDate.Equal(time.Date(2023, 11, 7, 18, 0, 0, 0, time.UTC)) || it.Build != "21B91" {
+		t.Errorf("dc:date item: date %v, build %q", it.PubDate, it.Build)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// histogramBucket is one bar of -format histogram.
+type histogramBucket struct {
+	label  string
+	count  int
+	counts map[string]int
+}
+
+// dominant is the platform with the most releases in the bucket; ties go to
+// the platform that comes first in product order.
+func (b histogramBucket) dominant() string {
+	best, bestCount := "other", 0
+	for _, key := range legendKeys() {
+		if n := b.counts[key]; n > bestCount {
+			best, bestCount = key, n
+		}
+	}
+	return best
+}
+
+// periodStart truncates t to the start of its day or ISO week in UTC.
+func periodStart(t time.Time, groupBy string) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	if groupBy == "week" {
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	}
+	return day
+}
+
+// unknownDateLabel is the bucket that day and week histograms count items
+// without a readable date in, after every dated one.
+const unknownDateLabel = "unknown"
+
+// histogramBuckets counts items per group label, newest first. Day and week
+// groups include the empty periods between releases so gaps in the cadence
+// show up; items whose date couldn't be read are left out of that range,
+// which would otherwise stretch back to 1970, and counted last under
+// unknownDateLabel. Platform groups follow the item order.
+func histogramBuckets(items []Item, groupBy string) []histogramBucket {
+	index := make(map[string]int)
+	var buckets []histogramBucket
+	add := func(label string) int {
+		if i, ok := index[label]; ok {
+			return i
+		}
+		index[label] = len(buckets)
+		buckets = append(buckets, histogramBucket{label: label, counts: make(map[string]int)})
+		return len(buckets) - 1
+	}
+
+	byDate := groupBy != "platform"
+	var newest, oldest time.Time
+	for _, it := range items {
+		if !byDate || !dateKnown(it.PubDate) {
+			continue
+		}
+		if newest.IsZero() || it.PubDate.After(newest) {
+			newest = it.PubDate
+		}
+		if oldest.IsZero() || it.PubDate.Before(oldest) {
+			oldest = it.PubDate
+		}
+	}
+	if !newest.IsZero() {
+		step := 1
+		if groupBy == "week" {
+			step = 7
+		}
+		first := periodStart(oldest, groupBy)
+		for p := periodStart(newest, groupBy); !p.Before(first); p = p.AddDate(0, 0, -step) {
+			add(groupLabel(Item{PubDate: p}, groupBy))
+		}
+	}
+
+	unknown := histogramBucket{label: unknownDateLabel, counts: make(map[string]int)}
+	for _, it := range items {
+		key := it.PlatformKey
+		if key == "" {
+			key = "other"
+		}
+		b := &unknown
+		if !byDate || dateKnown(it.PubDate) {
+			b = &buckets[add(groupLabel(it, groupBy))]
+		}
+		b.count++
+		b.counts[key]++
+	}
+	if unknown.count > 0 {
+		buckets = append(buckets, unknown)
+	}
+	return buckets
+}
+
+// renderHistogram draws one bar per group, scaled so the busiest group
+// fills the terminal width. Bars are colored after the dominant platform.
+func renderHistogram(items []Item, opts renderOptions, out io.Writer) {
+	buckets := histogramBuckets(items, opts.GroupBy)
+	if len(buckets) == 0 {
+		return
+	}
+	color := colorizer{enabled: opts.Color}
+
+	labelWidth, maxCount := 0, 0
+	for _, b := range buckets {
+		labelWidth = max(labelWidth, displayWidth(b.label))
+		maxCount = max(maxCount, b.count)
+	}
+	countWidth := len(fmt.Sprint(maxCount))
+	barSpace := opts.width() - opts.Indent - labelWidth - countWidth - 2
+	if barSpace < 1 {
+		barSpace = 1
+	}
+
+	block := "█"
+	if opts.ASCII {
+		block = "#"
+	}
+	for _, b := range buckets {
+		n := barLength(b.count, maxCount, barSpace)
+		bar := color.color(platformColor(b.dominant()), strings.Repeat(block, n))
+		fmt.Fprintf(out, "%s%s %*d %s\n", strings.Repeat(" ", opts.Indent), pad(b.label, labelWidth), countWidth, b.count, bar)
+	}
+}
+
+// barLength scales count to width, rounding to the nearest cell. Any
+// non-zero count gets at least one cell so it never looks empty.
+func barLength(count, maxCount, width int) int {
+	if count == 0 || maxCount == 0 {
+		return 0
+	}
+	return max(1, (count*width+maxCount/2)/maxCount)
+}
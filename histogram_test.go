@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBarLength(t *testing.T) {
+	tests := []struct {
+		count, maxCount, width, want int
+	}{
+		{0, 10, 50, 0},
+		{10, 10, 50, 50},
+		{5, 10, 50, 25},
+		{1, 3, 10, 3},
+		{2, 3, 10, 7},
+		{1, 1000, 50, 1},
+		{3, 0, 50, 0},
+	}
+	for _, tt := range tests {
+		if got := barLength(tt.count, tt.maxCount, tt.width); got != tt.want {
+			t.Errorf("barLength(%d, %d, %d) = %d, want %d", tt.count, tt.maxCount, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestRenderHistogramScalesToWidth(t *testing.T) {
+	items := []Item{
+		{PubDate: time.Date(2023, 11, 7, 18, 0, 0, 0, time.UTC), PlatformKey: "ios"},
+		{PubDate: time.Date(2023, 11, 7, 17, 0, 0, 0, time.UTC), PlatformKey: "ios"},
+		{PubDate: time.Date(2023, 11, 5, 17, 0, 0, 0, time.UTC), PlatformKey: "macos"},
+	}
+	opts := plainOptions(30)
+	opts.Indent = 0
+	opts.ASCII = true
+	var b strings.Builder
+	renderHistogram(items, opts, &b)
+
+	// 30 columns less the label, count and two spaces leave 17 for bars.
+	want := "2023-11-07 2 #################\n" +
+		"2023-11-06 0 \n" +
+		"2023-11-05 1 #########\n"
+	if got := b.String(); got != want {
+		t.Errorf("renderHistogram =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestHistogramBucketsSkipUnknownDates(t *testing.T) {
+	items := []Item{
+		{PubDate: time.Date(2023, 11, 7, 18, 0, 0, 0, time.UTC), PlatformKey: "ios"},
+		{PubDate: time.Unix(0, 0), PlatformKey: "macos"},
+		{PubDate: time.Date(2023, 11, 6, 17, 0, 0, 0, time.UTC), PlatformKey: "ios"},
+	}
+	buckets := histogramBuckets(items, "day")
+	var got []string
+	for _, b := range buckets {
+		got = append(got, b.label)
+	}
+	want := []string{"2023-11-07", "2023-11-06", unknownDateLabel}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("bucket labels = %v, want %v", got, want)
+	}
+	if last := buckets[len(buckets)-1]; last.count != 1 || last.counts["macos"] != 1 {
+		t.Errorf("unknown bucket = %+v, want one macOS item", last)
+	}
+
+	if buckets := histogramBuckets(items[1:2], "week"); len(buckets) != 1 || buckets[0].label != unknownDateLabel {
+		t.Errorf("only undated items: buckets = %+v, want just %q", buckets, unknownDateLabel)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// rawJSONFeed is the subset of JSON Feed (https://jsonfeed.org/version/1.1)
+// that maps onto rawItem.
+type rawJSONFeed struct {
+	Title       string        `json:"title"`
+	Description string        `json:"description"`
+	Items       []rawJSONItem `json:"items"`
+	NextURL     string        `json:"next_url"`
+}
+
+type rawJSONItem struct {
+	ID            string `json:"id"`
+	URL           string `json:"url"`
+	Title         string `json:"title"`
+	ContentHTML   string `json:"content_html"`
+	ContentText   string `json:"content_text"`
+	Summary       string `json:"summary"`
+	DatePublished string `json:"date_published"`
+}
+
+func parseJSONFeed(data []byte) (feedPage, error) {
+	var feed rawJSONFeed
+	if err := json.Unmarshal(data, &feed); err != nil {
+		return feedPage{}, &ParseError{Err: err}
+	}
+	items := make([]rawItem, 0, len(feed.Items))
+	for _, it := range feed.Items {
+		desc := it.ContentHTML
+		if desc == "" {
+			desc = it.ContentText
+		}
+		if desc == "" {
+			desc = it.Summary
+		}
+		items = append(items, rawItem{
+			Title:       it.Title,
+			Link:        it.URL,
+			PubDate:     it.DatePublished,
+			GUID:        it.ID,
+			Description: desc,
+		})
+	}
+	return feedPage{
+		feed:  Feed{Title: normalizeSpace(feed.Title), Description: normalizeSpace(feed.Description)},
+		items: items,
+		next:  strings.TrimSpace(feed.NextURL),
+	}, nil
+}
+
+// looksLikeJSON reports whether a feed body starts like a JSON document,
+// which is how -feed-format auto tells JSON Feed from RSS.
+func looksLikeJSON(data []byte) bool {
+	data = bytes.TrimLeft(data, " \t\r\n\ufeff")
+	return len(data) > 0 && (data[0] == '{' || data[0] == '[')
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// catalog maps message keys to translated text. Keys are "header.<column>"
+// for table headers, "header.<column>.short" for their narrow-terminal
+// form, and "platform.<key>" for platform labels.
+type catalog map[string]string
+
+// catalogs are the bundled translations for -locale. Apple's product names
+// stay as they are; only the generic words change.
+var catalogs = map[string]catalog{
+	"de": {
+		"header.date":                      "Veröffentlicht",
+		"header.platform":                  "Plattform",
+		"header.platform.short":            "BS",
+		"header.version":                   "Version (Build)",
+		"header.version.short":             "Version",
+		"header.version.build-first":       "Build (Version)",
+		"header.version.build-first.short": "Build",
+		"header.build":                     "Build",
+		"header.devices":                   "Geräte",
+		"header.device":                    "Gerät / Hinweise",
+		"header.title":                     "Titel",
+		"header.link":                      "Link",
+		"header.source":                    "Quelle",
+		"platform.other":                   "Andere",
+	},
+	"fr": {
+		"header.date":                      "Publié",
+		"header.platform":                  "Plateforme",
+		"header.platform.short":            "SE",
+		"header.version":                   "Version (build)",
+		"header.version.short":             "Version",
+		"header.version.build-first":       "Build (version)",
+		"header.version.build-first.short": "Build",
+		"header.build":                     "Build",
+		"header.devices":                   "Appareils",
+		"header.device":                    "Appareil / notes",
+		"header.title":                     "Titre",
+		"header.link":                      "Lien",
+		"header.source":                    "Source",
+		"platform.other":                   "Autre",
+	},
+}
+
+// messages is the catalog of the -locale in effect, or nil for English. It
+// is set once by parseFlags before anything is rendered.
+var messages catalog
+
+// selectLocale installs the catalog for locale: the bundled one, if any,
+// with the config file's messages for that locale laid over it. "en" and ""
+// select English.
+func selectLocale(locale string, custom map[string]map[string]string) error {
+	locale = strings.ToLower(strings.TrimSpace(locale))
+	messages = nil
+	if locale == "" || locale == "en" {
+		return nil
+	}
+	bundled, ok := catalogs[locale]
+	extra, hasExtra := custom[locale]
+	if !ok && !hasExtra {
+		return fmt.Errorf("unknown locale %q: use %s, or add it under messages in the config file", locale, strings.Join(knownLocales(), ", "))
+	}
+	messages = catalog{}
+	for k, v := range bundled {
+		messages[k] = v
+	}
+	for k, v := range extra {
+		messages[k] = v
+	}
+	return nil
+}
+
+func knownLocales() []string {
+	locales := []string{"en"}
+	for l := range catalogs {
+		locales = append(locales, l)
+	}
+	slices.Sort(locales[1:])
+	return locales
+}
+
+// translate looks key up in the current catalog, falling back to the
+// English text.
+func translate(key, english string) string {
+	if s, ok := messages[key]; ok && s != "" {
+		return s
+	}
+	return english
+}
+
+// headerText is the translated header of col, in its short form when
+// col has been shortened.
+func headerText(col column) string {
+	if col.header == "" {
+		return ""
+	}
+	key := "header." + col.key
+	if col.message != "" {
+		key = col.message
+	}
+	if col.shortHeader != "" && col.header == col.shortHeader {
+		key += ".short"
+	}
+	return translate(key, col.header)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestCatalogsHaveSameKeys(t *testing.T) {
+	var want []string
+	for _, c := range catalogs {
+		want = slices.Sorted(maps.Keys(c))
+		break
+	}
+	for locale, c := range catalogs {
+		if got := slices.Sorted(maps.Keys(c)); !slices.Equal(got, want) {
+			t.Errorf("catalog %q keys = %v, want %v", locale, got, want)
+		}
+	}
+}
+
+func TestSelectLocale(t *testing.T) {
+	t.Cleanup(func() { messages = nil })
+	custom := map[string]map[string]string{
+		"de": {"header.source": "Herkunft"},
+		"nl": {"header.link": "Koppeling"},
+	}
+
+	if err := selectLocale("de", custom); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{
+		"header.link":   "Link",
+		"header.source": "Herkunft",
+		"header.date":   "Veröffentlicht",
+	} {
+		if got := translate(key, "?"); got != want {
+			t.Errorf("de: translate(%q) = %q, want %q", key, got, want)
+		}
+	}
+
+	if err := selectLocale("nl", custom); err != nil {
+		t.Fatal(err)
+	}
+	if got := translate("header.link", "Link"); got != "Koppeling" {
+		t.Errorf("nl: translate(header.link) = %q, want %q", got, "Koppeling")
+	}
+	if got := translate("header.date", "Published"); got != "Published" {
+		t.Errorf("nl: untranslated header.date = %q, want the English text", got)
+	}
+
+	if err := selectLocale("xx", custom); err == nil {
+		t.Error("unknown locale xx: no error")
+	}
+}
@@ -1,76 +1,472 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/xml"
+	"errors"
 	"flag"
 	"fmt"
+	"hash/fnv"
 	"html"
 	"io"
-	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 const (
-	defaultFeedURL = "https://ipsw.me/timeline.rss"
-	defaultLimit   = 15
-	defaultTimeout = 10
-	defaultColor   = "auto"
+	defaultFeedURL     = "https://ipsw.me/timeline.rss"
+	defaultLimit       = 15
+	defaultTimeout     = 10
+	defaultMaxFeedSize = "8MB"
+	defaultMaxPages    = 5
+	defaultColor       = "auto"
+	defaultFormat      = "table"
+	defaultIndent      = 2
+	defaultGap         = 1
+
+	defaultReleasedPhrases = "has been released,released"
+	defaultNotesPhrase     = "has been released"
+	defaultPreRelease      = "beta,public beta,developer preview,seed,rc=rc,release candidate=rc,gm=rc"
+
+	defaultNotesPolicy  = "device-then-notes"
+	defaultMinDeviceLen = 0
+
+	defaultInterval        = 5 * time.Minute
+	defaultMaxIdleConns    = 4
+	defaultIdleConnTimeout = 90 * time.Second
 )
 
+// rawRSS requires an <rss> root, so an HTML error page served in place of
+// the feed is a parse error rather than an empty feed.
 type rawRSS struct {
+	XMLName xml.Name   `xml:"rss"`
 	Channel rawChannel `xml:"channel"`
 }
 
 type rawChannel struct {
-	Items []rawItem `xml:"item"`
+	Title         string    `xml:"title"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rawItem `xml:"item"`
+	// Links are the channel's atom:link elements; rel="next" points at the
+	// next page of a paginated feed (RFC 5005).
+	Links []rawAtomLink `xml:"http://www.w3.org/2005/Atom link"`
 }
 
+type rawAtomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+}
+
+// rawItem is one feed entry before normalization. RSS items are read by
+// UnmarshalXML; JSON Feed items are mapped onto it by parseJSONFeed.
 type rawItem struct {
-	Title       string `xml:"title"`
-	Link        string `xml:"link"`
-	PubDate     string `xml:"pubDate"`
-	GUID        string `xml:"guid"`
-	Description string `xml:"description"`
+	Title       string
+	Link        string
+	PubDate     string
+	GUID        string
+	Description string
+}
+
+// XML namespaces of the extension elements UnmarshalXML understands.
+const (
+	atomNamespace = "http://www.w3.org/2005/Atom"
+	dcNamespace   = "http://purl.org/dc/elements/1.1/"
+)
+
+// UnmarshalXML reads an RSS <item> by namespace, so extension elements that
+// share a local name with an RSS one (atom:link, dc:title) don't overwrite
+// it. An atom:link with rel="alternate" (or no rel) is preferred over the
+// RSS <link>, and dc:date and dc:title stand in for a missing <pubDate> or
+// <title>.
+func (r *rawItem) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var rssLink, atomLink, dcDate, dcTitle string
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.EndElement:
+			r.Link = rssLink
+			if atomLink != "" {
+				r.Link = atomLink
+			}
+			if strings.TrimSpace(r.PubDate) == "" {
+				r.PubDate = dcDate
+			}
+			if strings.TrimSpace(r.Title) == "" {
+				r.Title = dcTitle
+			}
+			return nil
+		case xml.StartElement:
+			var field *string
+			switch t.Name {
+			case xml.Name{Local: "title"}:
+				field = &r.Title
+			case xml.Name{Local: "link"}:
+				field = &rssLink
+			case xml.Name{Local: "pubDate"}:
+				field = &r.PubDate
+			case xml.Name{Local: "guid"}:
+				field = &r.GUID
+			case xml.Name{Local: "description"}:
+				field = &r.Description
+			case xml.Name{Space: dcNamespace, Local: "date"}:
+				field = &dcDate
+			case xml.Name{Space: dcNamespace, Local: "title"}:
+				field = &dcTitle
+			case xml.Name{Space: atomNamespace, Local: "link"}:
+				var l rawAtomLink
+				if err := d.DecodeElement(&l, &t); err != nil {
+					return err
+				}
+				if (l.Rel == "" || l.Rel == "alternate") && atomLink == "" {
+					atomLink = strings.TrimSpace(l.Href)
+				}
+				continue
+			}
+			if field == nil {
+				if err := d.Skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := d.DecodeElement(field, &t); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Feed is the channel-level metadata of a loaded feed. LastBuildDate is
+// zero when the feed doesn't say when it was last built.
+type Feed struct {
+	URL           string
+	Title         string
+	Description   string
+	LastBuildDate time.Time
 }
 
 type Item struct {
-	Title          string
-	Link           string
-	PubDate        time.Time
-	GUID           string
-	Description    string
-	PlatformKey    string
-	PlatformLabel  string
-	Version        string
-	Build          string
-	DeviceOrNotes  string
-	PreRelease     bool
-	RawDevice      string
+	Title         string
+	Link          string
+	PubDate       time.Time
+	GUID          string
+	Description   string
+	PlatformKey   string
+	PlatformLabel string
+	Version       string
+	Build         string
+	DeviceOrNotes string
+	PreRelease    bool
+	// PreReleaseStage is "beta" or "rc" for pre-releases, and
+	// PreReleaseKeyword the keyword in the title that marked it.
+	PreReleaseStage   string
+	PreReleaseKeyword string
+	RawDevice         string
+	// Devices is RawDevice split into device names.
+	Devices        []string
 	Notes          string
 	DisplayDate    string
 	DisplayVersion string
+	Provenance     string
+	Source         string
+	New            bool
+	// UnknownPlatform is the platform as written in the title when it
+	// matched no known platform and was bucketed as "other".
+	UnknownPlatform string
+	// Change is "added" or "removed" for the rows of a diff.
+	Change string
+	// SecurityContent is set when the title or description mentions a CVE
+	// or security fixes.
+	SecurityContent bool
 }
 
+// Item provenance values. Expected items come from --expected-feed and
+// describe rumored or announced releases that have not shipped yet.
+const (
+	provenanceReleased = "released"
+	provenanceExpected = "expected"
+)
+
 type Config struct {
-	FeedURL  string
-	Limit    int
-	Contains string
-	Timeout  time.Duration
-	Color    string
+	Command            string
+	Feeds              []string
+	FeedLabels         []string
+	SourcePriority     []string
+	DedupeBy           string
+	WarnDuplicateGUIDs bool
+	Fields             []string
+	Columns            string
+	Sort               string
+	GroupBy            string
+	PlatformOrder      []string
+	Pin                []string
+	Platforms          []string
+	PlatformLimits     map[string]int
+	ShowBuild          bool
+	EmptyMessage       string
+	FailEmpty          bool
+	StateFile          string
+	MarkNew            bool
+	SinceLastRun       bool
+	StrictPlatforms    bool
+	ConfigPath         string
+	Check              bool
+	Verbose            bool
+	PrintSchema        bool
+	Audit              bool
+	StripANSI          bool
+	Output             string
+	Limit              int
+	Contains           []string
+	Exclude            []string
+	CaseSensitive      bool
+	HasNotes           bool
+	HasNoNotes         bool
+	Timeout            time.Duration
+	Retries            int
+	AttemptTimeout     time.Duration
+	ConnectTimeout     time.Duration
+	HeaderTimeout      time.Duration
+	Deadline           time.Duration
+	NoJitter           bool
+	MaxFeedSize        int64
+	MaxIdleConns       int
+	IdleConnTimeout    time.Duration
+	HTTP1              bool
+	CacheDir           string
+	CacheParsed        bool
+	CacheTTL           time.Duration
+	Revalidate         bool
+	NoStaleFallback    bool
+	Offline            bool
+	FollowNext         bool
+	MaxPages           int
+	DumpRaw            string
+	Color              string
+	Latest             bool
+	Porcelain          bool
+	Format             string
+	NormalizeVersion   string
+	NotesPolicy        string
+	MaxTitleLen        int
+	FeedFormat         string
+	ReleasedPhrases    []string
+	NotesPhrase        string
+	PreserveWhitespace bool
+	PreReleaseKeywords []preReleaseKeyword
+	TitleRewrites      []titleRewrite
+	SortDevices        bool
+	MinDeviceLen       int
+	ASCIIStripe        bool
+	Indent             int
+	Gap                int
+	Legend             bool
+	HighlightAge       time.Duration
+	DimOld             bool
+	ShortPlatform      bool
+	SplitVersionBuild  bool
+	NoSanitize         bool
+	MarkSecurity       bool
+	DeviceCount        bool
+	CompactDates       bool
+	Divider            string
+	EmptyNotes         string
+	NotesPlaceholder   string
+	NoTruncate         bool
+	Ellipsis           string
+	BuildFirst         bool
+	PadToWidth         bool
+	CollapseDuplicates bool
+	Hyperlinks         bool
+	FitHeight          bool
+	ShowFeedInfo       bool
+	JSONArray          bool
+	StaleAfter         time.Duration
+	NewSinceBuild      map[string]buildTrain
+	MinVersions        map[string]releaseVersion
+	MaxAge             time.Duration
+	FoldPreRelease     bool
+	OutputDir          string
+	Formats            []string
+	// Now replaces the current time for everything relative to it (-now).
+	Now               time.Time
+	PlatformSummary   bool
+	IncludePreRelease bool
+	Quiet             bool
+	Trace             bool
+	ExpectedFeed      string
+	Interval          time.Duration
+	RefreshOnSignal   bool
+	DiffOld           string
+	DiffNew           string
+}
+
+// renderOptions controls how renderTable draws the table.
+type renderOptions struct {
+	Color            bool
+	ASCII            bool
+	Fields           []string
+	Columns          string
+	NormalizeVersion string
+	GroupBy          string
+	Indent           int
+	Gap              int
+	Legend           bool
+	// HighlightAge marks items published within this long before Now.
+	HighlightAge time.Duration
+	DimOld       bool
+	Now          time.Time
+	// Layout is the narrow-terminal step chosen by renderTable.
+	Layout            int
+	ShortPlatform     bool
+	SplitVersionBuild bool
+	MarkSecurity      bool
+	DeviceCount       bool
+	// Sanitize escapes control characters in items before drawing them.
+	Sanitize     bool
+	CompactDates bool
+	Divider      string
+	// EmptyNotes is -group-empty-notes: blank, placeholder or hide.
+	EmptyNotes       string
+	NotesPlaceholder string
+	NoTruncate       bool
+	// Ellipsis marks text cut to fit its column; empty cuts without one.
+	Ellipsis string
+	// BuildFirst leads the version column with the build (-build-first).
+	BuildFirst bool
+	// Overflow is the key of the last column when -no-truncate lets it run
+	// past its width; set by renderTable.
+	Overflow   string
+	Hyperlinks bool
+	// Width is the width to fit the table to; 0 means the terminal's.
+	Width int
+	// PadToWidth pads every line of the table out to Width with spaces.
+	PadToWidth bool
+	// CollapseRows draws a run of identical rows as one, marked with the
+	// run's length (-collapse-duplicates).
+	CollapseRows bool
+	// MaxLines caps the table at this many lines, dropping rows from the
+	// end (-fit-height); 0 means no cap.
+	MaxLines int
+	// Gutter adds the +/- column of a diff in front of the others.
+	Gutter bool
+}
+
+// Age bands for -dim-old: rows older than dimAfter fade, and rows older
+// than fadeAfter lose their colors as well.
+const (
+	dimAfter  = 7 * 24 * time.Hour
+	fadeAfter = 30 * 24 * time.Hour
+)
+
+// ageBand is 0 for items published within dimAfter of now (and for
+// expected items), 1 up to fadeAfter and 2 beyond.
+func ageBand(it Item, now time.Time) int {
+	if it.Provenance == provenanceExpected {
+		return 0
+	}
+	switch age := now.Sub(it.PubDate); {
+	case age > fadeAfter:
+		return 2
+	case age > dimAfter:
+		return 1
+	}
+	return 0
+}
+
+// compactDateWidth fits the time of day shown with -compact-dates.
+const compactDateWidth = 9
+
+// compactDates reports whether rows show only the time of day. That needs
+// day dividers to carry the date, so other groupings keep full dates.
+func (o renderOptions) compactDates() bool {
+	return o.CompactDates && o.GroupBy == "day"
+}
+
+// encodedFormats escape their content themselves, so renderItems hands them
+// feed text as it came.
+var encodedFormats = map[string]bool{"json": true, "rss": true, "html": true}
+
+func tableOptions(cfg Config) renderOptions {
+	groupBy := cfg.GroupBy
+	if groupBy == "" {
+		groupBy = groupByForSort(cfg.Sort)
+	}
+	return renderOptions{
+		Color:             shouldEnableColor(cfg.Color),
+		Sanitize:          !cfg.NoSanitize,
+		ASCII:             cfg.ASCIIStripe || !unicodeSupported(),
+		Fields:            cfg.Fields,
+		Columns:           cfg.Columns,
+		NormalizeVersion:  cfg.NormalizeVersion,
+		GroupBy:           groupBy,
+		Indent:            cfg.Indent,
+		Gap:               cfg.Gap,
+		Legend:            cfg.Legend,
+		HighlightAge:      cfg.HighlightAge,
+		DimOld:            cfg.DimOld,
+		Now:               cfg.Now,
+		ShortPlatform:     cfg.ShortPlatform,
+		SplitVersionBuild: cfg.SplitVersionBuild,
+		MarkSecurity:      cfg.MarkSecurity,
+		DeviceCount:       cfg.DeviceCount,
+		PadToWidth:        cfg.PadToWidth,
+		CollapseRows:      cfg.CollapseDuplicates,
+		CompactDates:      cfg.CompactDates,
+		Divider:           cfg.Divider,
+		EmptyNotes:        cfg.EmptyNotes,
+		NotesPlaceholder:  cfg.NotesPlaceholder,
+		NoTruncate:        cfg.NoTruncate,
+		Ellipsis:          cfg.Ellipsis,
+		BuildFirst:        cfg.BuildFirst,
+		Hyperlinks:        cfg.Hyperlinks,
+		MaxLines:          fitHeightLines(cfg),
+	}
+}
+
+// fitHeightLines is the line budget for -fit-height: the terminal's height
+// when the table goes to one, or 0 when it doesn't.
+func fitHeightLines(cfg Config) int {
+	if !cfg.FitHeight || cfg.Output != "" || !isTTY() {
+		return 0
+	}
+	return terminalHeight()
 }
 
 type colorizer struct {
 	enabled bool
+	// fade is the -dim-old band of the row being drawn: 1 adds faint to
+	// every color, 2 replaces the colors with faint alone.
+	fade int
+	// tint replaces every color of the row, as for the rows of a diff.
+	tint string
 }
 
 func (c colorizer) wrap(code string, s string) string {
 	if !c.enabled || code == "" || s == "" {
 		return s
 	}
+	switch {
+	case c.tint != "":
+		code = c.tint
+	case c.fade >= 2:
+		code = "2"
+	case c.fade == 1:
+		code = "2;" + code
+	}
 	return "\033[" + code + "m" + s + "\033[0m"
 }
 
@@ -84,71 +480,1081 @@ func (c colorizer) color(code string, s string) string {
 
 func main() {
 	cfg := parseFlags()
+	if cfg.PrintSchema {
+		if err := renderSchema(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, "write error:", err)
+			os.Exit(exitError)
+		}
+		return
+	}
+	if cfg.StripANSI {
+		if err := copyStripped(os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, "strip-ansi:", err)
+			os.Exit(exitError)
+		}
+		return
+	}
+	if cfg.Check {
+		runCheck(cfg)
+		return
+	}
+	if cfg.Audit {
+		runAudit(cfg)
+		return
+	}
 
-	data, err := fetchFeed(cfg.FeedURL, cfg.Timeout)
+	switch cfg.Command {
+	case "watch":
+		runWatch(cfg)
+	case "diff":
+		runDiff(cfg)
+	case "doctor":
+		runDoctor(cfg)
+	default:
+		runList(cfg)
+	}
+}
+
+func runList(cfg Config) {
+	f := newFetcher(cfg)
+	items, err := loadFeeds(f, cfg)
+	if !cfg.FailEmpty {
+		err = allowEmpty(err)
+	}
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "fetch error (%s): %v\n", cfg.FeedURL, err)
-		os.Exit(1)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitCode(err))
+	}
+
+	var state runState
+	if cfg.StateFile != "" {
+		state, err = loadState(cfg.StateFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "state error:", err)
+			os.Exit(exitError)
+		}
 	}
 
-	rawItems, err := parseFeed(data)
+	filtered := filterSelection(items, cfg)
+	if cfg.SinceLastRun {
+		filtered = newerThanState(filtered, state)
+	}
+	selected := limitSelection(filtered, cfg)
+	if cfg.MarkNew {
+		markNew(selected, state)
+	}
+
+	var feeds []Feed
+	if cfg.ShowFeedInfo {
+		feeds = f.feeds
+	}
+	switch {
+	case cfg.OutputDir != "":
+		err = writeOutputDir(selected, feeds, cfg)
+	case cfg.Output != "":
+		err = writeOutput(selected, feeds, cfg)
+	default:
+		err = renderItems(selected, feeds, cfg, os.Stdout)
+	}
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "parse error (%s): %v\n", cfg.FeedURL, err)
-		os.Exit(1)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitCode(err))
 	}
 
+	if cfg.StateFile != "" {
+		if err := saveState(cfg.StateFile, state.advance(selected)); err != nil {
+			fmt.Fprintln(os.Stderr, "state error:", err)
+			os.Exit(exitError)
+		}
+	}
+	if cfg.Verbose {
+		fmt.Fprintln(os.Stderr, f.stats.summary(len(filtered), len(selected)))
+	}
+	if len(selected) == 0 && cfg.FailEmpty {
+		os.Exit(exitEmpty)
+	}
+}
+
+// renderItems writes items in the configured output format. With no items
+// the table prints -empty-message, or nothing when it is unset. When feeds
+// are given (-show-feed-info), the table is preceded by a line per feed and
+// JSON items are wrapped in an object carrying the feeds.
+func renderItems(items []Item, feeds []Feed, cfg Config, out io.Writer) error {
+	if !cfg.NoSanitize && (cfg.Porcelain || !encodedFormats[cfg.Format]) {
+		items, feeds = sanitizeItems(items), sanitizeFeeds(feeds)
+		cfg.NoSanitize = true
+	}
+	if cfg.Porcelain {
+		renderPorcelain(items, out)
+		return nil
+	}
+	switch cfg.Format {
+	case "json":
+		render := renderJSON
+		switch {
+		case !cfg.JSONArray:
+			render = renderJSONLines
+		case cfg.Latest:
+			render = renderJSONByPlatform
+		}
+		if cfg.ShowFeedInfo {
+			render = func(items []Item, out io.Writer) error { return renderJSONWithFeeds(items, feeds, cfg.Latest, out) }
+		}
+		if err := render(items, out); err != nil {
+			return fmt.Errorf("write error: %w", err)
+		}
+		return nil
+	case "badge":
+		return renderBadge(items, cfg.ShowBuild, cfg.BuildFirst, out)
+	case "histogram":
+		renderHistogram(items, tableOptions(cfg), out)
+		return nil
+	case "env":
+		if err := renderEnv(items, out); err != nil {
+			return fmt.Errorf("write error: %w", err)
+		}
+		return nil
+	case "rss":
+		if err := renderRSS(items, out); err != nil {
+			return fmt.Errorf("write error: %w", err)
+		}
+		return nil
+	case "html":
+		if err := renderHTML(items, cfg.BuildFirst, out); err != nil {
+			return fmt.Errorf("write error: %w", err)
+		}
+		return nil
+	}
+
+	if cfg.ShowFeedInfo {
+		renderFeedInfo(feeds, tableOptions(cfg), out)
+	}
+	if cfg.PlatformSummary {
+		renderPlatformSummary(items, tableOptions(cfg), out)
+		return nil
+	}
+	if len(items) == 0 {
+		if cfg.EmptyMessage != "" {
+			fmt.Fprintln(out, cfg.EmptyMessage)
+		}
+		return nil
+	}
+	if _, err := io.WriteString(out, renderTableString(items, tableOptions(cfg))); err != nil {
+		return fmt.Errorf("write error: %w", err)
+	}
+	return nil
+}
+
+// loadFeeds loads every configured feed, de-duplicates across them and,
+// when configured, merges in the expected-releases feed. When the feeds hold
+// no items at all the error is an EmptyFeedError; see allowEmpty.
+func loadFeeds(f *fetcher, cfg Config) ([]Item, error) {
+	var items []Item
+	for i, feedURL := range cfg.Feeds {
+		feedItems, err := loadItems(f, feedURL, normalizeOptionsFor(cfg))
+		if err != nil {
+			return nil, err
+		}
+		if i < len(cfg.FeedLabels) {
+			for j := range feedItems {
+				feedItems[j].Source = cfg.FeedLabels[i]
+			}
+		}
+		items = append(items, feedItems...)
+	}
+	items = dedupeItems(items, cfg.SourcePriority, cfg.DedupeBy, cfg.WarnDuplicateGUIDs)
+
+	if cfg.ExpectedFeed != "" {
+		expected, err := loadItems(f, cfg.ExpectedFeed, normalizeOptionsFor(cfg))
+		if err != nil {
+			return nil, err
+		}
+		items = mergeExpected(items, expected)
+	}
+
+	applyNotesPolicy(items, cfg.NotesPolicy, cfg.MinDeviceLen)
+
+	if cfg.StrictPlatforms {
+		if err := checkPlatforms(items); err != nil {
+			return nil, err
+		}
+	}
+	if len(items) == 0 {
+		return nil, &EmptyFeedError{URLs: cfg.Feeds}
+	}
+	return items, nil
+}
+
+// allowEmpty drops an EmptyFeedError, for callers that show empty feeds
+// like any other run where nothing matches.
+func allowEmpty(err error) error {
+	var emptyErr *EmptyFeedError
+	if errors.As(err, &emptyErr) {
+		return nil
+	}
+	return err
+}
+
+// loadItems runs the fetch, parse and normalize stages for a single feed.
+func loadItems(f *fetcher, feedURL string, norm normalizeOptions) ([]Item, error) {
+	if path, ok := strings.CutPrefix(feedURL, "file://"); ok {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			return loadDir(f, path, norm)
+		}
+	}
+
+	// -deadline covers every page of the feed, not each one separately.
+	ctx := context.Background()
+	if f.deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.deadline)
+		defer cancel()
+	}
+
+	resp, err := f.fetchFeed(ctx, feedURL)
+	if err != nil {
+		return nil, err
+	}
+
+	// With -cache-parsed, a body seen before skips parsing and
+	// normalization. Paginated feeds span several bodies and aren't cached.
+	usesParsed := f.parsed != nil && !f.followNext
+	var parsed *parsedEntry
+	if usesParsed {
+		parsed = f.parsed.loadParsed(feedURL, parsedKey(resp.Body, norm))
+	}
+
+	var page feedPage
+	if parsed != nil {
+		page = feedPage{feed: parsed.Feed}
+	} else {
+		page, err = f.parsePage(resp.Body, norm.FeedFormat)
+	}
+	if err != nil && !resp.FromCache && f.staleFallback && !strings.HasPrefix(feedURL, "file://") {
+		// A garbled body is often a transient error page, so try once more
+		// before falling back to the last good copy.
+		if retry, retryErr := f.fetchFeed(ctx, feedURL); retryErr == nil {
+			if retryPage, parseErr := f.parsePage(retry.Body, norm.FeedFormat); parseErr == nil {
+				resp, page, err = retry, retryPage, nil
+			}
+		}
+	}
+	fresh := err == nil
+	rawItems := page.items
+	if err != nil {
+		var parseErr *ParseError
+		if errors.As(err, &parseErr) {
+			parseErr.URL = feedURL
+		}
+		stale, ok := f.staleItems(feedURL, norm.FeedFormat)
+		if !ok {
+			return nil, err
+		}
+		warnf("%v; showing the cached copy from %s", err, stale.fetchedAt.Local().Format("2006-01-02 15:04"))
+		rawItems = stale.items
+	} else {
+		if err := f.commit(feedURL, resp); err != nil {
+			fmt.Fprintf(os.Stderr, "cache error: %v\n", err)
+		}
+		page.feed.URL = feedURL
+		f.recordFeed(page.feed)
+		if built := page.feed.LastBuildDate; f.staleAfter > 0 && !built.IsZero() {
+			if age := f.now().Sub(built); age > f.staleAfter {
+				warnf("%s was last built %s ago (%s); the feed may have stopped updating", feedURL, formatAge(age), built.UTC().Format("2006-01-02 15:04 UTC"))
+			}
+		}
+		if f.followNext && page.next != "" {
+			rawItems = append(rawItems, followPages(ctx, f, feedURL, page.next, norm.FeedFormat)...)
+		}
+	}
+
+	if parsed != nil {
+		f.stats.rawItems += len(parsed.Items)
+		return parsed.Items, nil
+	}
+
+	f.stats.rawItems += len(rawItems)
 	items := make([]Item, 0, len(rawItems))
 	for _, r := range rawItems {
-		items = append(items, normalizeItem(r))
+		it := normalizeItem(r, norm)
+		it.Source = feedURL
+		items = append(items, it)
 	}
+	if usesParsed && fresh {
+		entry := parsedEntry{Key: parsedKey(resp.Body, norm), Feed: page.feed, Items: items}
+		if err := f.parsed.storeParsed(feedURL, entry); err != nil {
+			fmt.Fprintf(os.Stderr, "cache error: %v\n", err)
+		}
+	}
+	return items, nil
+}
 
-	filtered := filterItems(items, cfg.Contains)
-	sort.Slice(filtered, func(i, j int) bool {
-		return filtered[i].PubDate.After(filtered[j].PubDate)
-	})
+// followPages reads the pages after the first one of a paginated feed, up
+// to -max-pages in all. Next links are resolved against the page that holds
+// them. A page that fails to load ends the walk with a warning, keeping the
+// items read so far; so does a link back to a page already read.
+func followPages(ctx context.Context, f *fetcher, feedURL, next, format string) []rawItem {
+	var items []rawItem
+	seen := map[string]bool{feedURL: true}
+	current := feedURL
+	for pages := 1; next != ""; pages++ {
+		base, err := url.Parse(current)
+		if err != nil {
+			warnf("%s: %v", current, err)
+			break
+		}
+		ref, err := url.Parse(next)
+		if err != nil {
+			warnf("%s: bad next link %q", current, next)
+			break
+		}
+		pageURL := base.ResolveReference(ref).String()
+		if seen[pageURL] {
+			warnf("%s: %s links back to a page already read; stopping there", feedURL, current)
+			break
+		}
+		if pages >= f.maxPages {
+			warnf("%s: more pages than -max-pages %d; stopping there", feedURL, f.maxPages)
+			break
+		}
+		seen[pageURL] = true
+
+		resp, err := f.fetchFeed(ctx, pageURL)
+		if err != nil {
+			warnf("%v; skipping the remaining pages", err)
+			break
+		}
+		page, err := f.parsePage(resp.Body, format)
+		if err != nil {
+			warnf("%s: %v; skipping the remaining pages", pageURL, err)
+			break
+		}
+		if err := f.commit(pageURL, resp); err != nil {
+			fmt.Fprintf(os.Stderr, "cache error: %v\n", err)
+		}
+		items = append(items, page.items...)
+		current, next = pageURL, page.next
+	}
+	return items
+}
+
+// selectItems applies filtering, ordering and limits to normalized items.
+func selectItems(items []Item, cfg Config) []Item {
+	return limitSelection(filterSelection(items, cfg), cfg)
+}
+
+// filterSelection applies the filters of selectItems and sorts what is
+// left newest first.
+func filterSelection(items []Item, cfg Config) []Item {
+	filtered := filterItems(items, cfg.Contains, cfg.Exclude, cfg.CaseSensitive)
+	filtered = filterPlatforms(filtered, cfg.Platforms)
+	if cfg.HasNotes || cfg.HasNoNotes {
+		filtered = filterNotes(filtered, cfg.HasNotes)
+	}
+	filtered = filterNewTrains(filtered, cfg.NewSinceBuild)
+	filtered = filterMinVersions(filtered, cfg.MinVersions)
+	filtered = filterMaxAge(filtered, cfg.MaxAge, cfg.Now)
+	if cfg.FoldPreRelease {
+		filtered = foldPreReleases(filtered)
+	}
+	sortItems(filtered, "date", nil, nil)
+
+	if cfg.PlatformSummary {
+		filtered = releasedOnly(filtered, cfg.IncludePreRelease)
+	}
+	return filtered
+}
+
+// limitSelection cuts the output of filterSelection down to what is shown:
+// the latest per platform when asked, in the final order, within the
+// per-platform and overall limits.
+func limitSelection(filtered []Item, cfg Config) []Item {
+	if cfg.Latest || cfg.PlatformSummary {
+		filtered = latestPerPlatform(filtered)
+	}
+	sortBy := cfg.Sort
+	if cfg.PlatformSummary {
+		sortBy = "platform"
+	}
+	sortItems(filtered, sortBy, cfg.PlatformOrder, cfg.Pin)
+	filtered = limitPerPlatform(filtered, cfg.PlatformLimits)
 
 	if cfg.Limit > 0 && len(filtered) > cfg.Limit {
 		filtered = filtered[:cfg.Limit]
 	}
+	return filtered
+}
+
+type command struct {
+	name    string
+	summary string
+}
+
+var commands = []command{
+	{"list", "show recent releases (default)"},
+	{"latest", "show the newest release for each platform"},
+	{"watch", "poll the feed and redraw when it changes"},
+	{"diff", "compare two feeds: diff OLD [NEW]"},
+	{"doctor", "check connectivity, parsing and terminal setup"},
+}
 
-	if len(filtered) == 0 {
+func isCommand(name string) bool {
+	for _, c := range commands {
+		if c.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// stringList is a repeatable string flag. The first Set replaces the
+// default, so "-f a -f b" yields [a b] rather than [default a b].
+type stringList struct {
+	values []string
+	set    bool
+}
+
+func (l *stringList) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(l.values, ",")
+}
+
+func (l *stringList) Set(s string) error {
+	if !l.set {
+		l.values = nil
+		l.set = true
+	}
+	l.values = append(l.values, s)
+	return nil
+}
+
+type flagValues struct {
+	feeds           stringList
+	labels          stringList
+	priority        string
+	dedupeBy        string
+	warnDupGUIDs    bool
+	fields          string
+	columns         string
+	rawTitle        bool
+	sortBy          string
+	groupBy         string
+	platOrder       string
+	pin             string
+	platforms       string
+	perPlatform     int
+	showBuild       bool
+	emptyMsg        string
+	failEmpty       bool
+	stateFile       string
+	markNew         bool
+	sinceLastRun    bool
+	strict          bool
+	configPath      string
+	dumpConfig      bool
+	check           bool
+	verbose         bool
+	printSchema     bool
+	audit           bool
+	stripANSI       bool
+	output          string
+	timeoutSec      int
+	retries         int
+	attemptTO       time.Duration
+	connectTO       time.Duration
+	headerTO        time.Duration
+	deadline        time.Duration
+	noJitter        bool
+	maxSize         string
+	maxIdle         int
+	idleTime        time.Duration
+	http1           bool
+	cacheDir        string
+	cacheTTL        time.Duration
+	cacheParsed     bool
+	revalidate      bool
+	noStale         bool
+	offline         bool
+	followNext      bool
+	maxPages        int
+	dumpRaw         string
+	maxTitle        int
+	feedFormat      string
+	releasedPhrases string
+	notesPhrase     string
+	preserveWS      bool
+	preRelease      string
+	titleReplace    stringList
+	titleRegex      stringList
+	sortDevices     bool
+	color           string
+	limit           int
+	contains        stringList
+	exclude         stringList
+	caseSensitive   bool
+	hasNotes        bool
+	hasNoNotes      bool
+	porcelain       bool
+	format          string
+	normVer         string
+	notesPolicy     string
+	minDevice       int
+	ascii           bool
+	indent          int
+	gap             int
+	legend          bool
+	highlightAge    durationValue
+	dimOld          bool
+	shortPlat       bool
+	splitBuild      bool
+	locale          string
+	noSanitize      bool
+	markSecurity    bool
+	deviceCount     bool
+	compactDates    bool
+	divider         string
+	emptyNotes      string
+	placeholder     string
+	noTruncate      bool
+	padToWidth      bool
+	collapseDups    bool
+	hyperlinks      bool
+	ellipsis        string
+	buildFirst      bool
+	noEllipsis      bool
+	fitHeight       bool
+	showFeedInfo    bool
+	jsonArray       bool
+	staleAfter      durationValue
+	sinceBuild      string
+	minVersion      string
+	maxAge          durationValue
+	foldPre         bool
+	outputDir       string
+	formats         string
+	now             string
+	summary         bool
+	includePre      bool
+	quiet           bool
+	trace           bool
+	expected        string
+	interval        time.Duration
+	refreshSig      bool
+}
+
+func addSharedFlags(fs *flag.FlagSet, v *flagValues) {
+	fs.Var(&v.feeds, "feed-url", "RSS feed URL (repeatable)")
+	fs.Var(&v.feeds, "f", "RSS feed URL (shorthand)")
+	fs.Var(&v.labels, "feed-label", "Label for the matching -feed-url, used as the item source (repeatable)")
+	fs.StringVar(&v.priority, "source-priority", v.priority, "Comma-separated sources preferred when de-duplicating")
+	fs.StringVar(&v.dedupeBy, "deduplicate-by", v.dedupeBy, "What makes items duplicates: guid|link|platform+version+build")
+	fs.BoolVar(&v.warnDupGUIDs, "warn-duplicate-guids", v.warnDupGUIDs, "Warn when items sharing a GUID differ in content, a likely feed bug")
+
+	fs.IntVar(&v.timeoutSec, "timeout", v.timeoutSec, "HTTP timeout in seconds")
+	fs.IntVar(&v.timeoutSec, "t", v.timeoutSec, "HTTP timeout in seconds (shorthand)")
+
+	fs.IntVar(&v.retries, "retries", v.retries, "Retries after a failed fetch (timeouts, connection failures, 5xx, 429)")
+	fs.DurationVar(&v.attemptTO, "per-attempt-timeout", v.attemptTO, "Timeout for each fetch attempt (default: -timeout)")
+	fs.DurationVar(&v.connectTO, "connect-timeout", v.connectTO, "Timeout for connecting, including the TLS handshake (0: only -timeout applies)")
+	fs.DurationVar(&v.headerTO, "header-timeout", v.headerTO, "Timeout for the response headers once the request is sent (0: only -timeout applies)")
+	fs.DurationVar(&v.deadline, "deadline", v.deadline, "Overall time limit for a fetch including retries (0 disables)")
+	fs.BoolVar(&v.noJitter, "no-jitter", v.noJitter, "Wait exactly the backoff between retries instead of a random ±20% around it")
+
+	fs.StringVar(&v.maxSize, "max-feed-size", v.maxSize, "Largest feed body to accept, e.g. 512KB or 8MB (0 disables)")
+
+	fs.IntVar(&v.maxIdle, "max-idle-conns", v.maxIdle, "Idle keep-alive connections to keep open")
+	fs.DurationVar(&v.idleTime, "idle-conn-timeout", v.idleTime, "How long idle keep-alive connections stay open")
+	fs.BoolVar(&v.http1, "http1", v.http1, "Disable HTTP/2 and use HTTP/1.1 only")
+
+	fs.StringVar(&v.cacheDir, "cache-dir", v.cacheDir, "Directory for cached feeds")
+	fs.DurationVar(&v.cacheTTL, "cache-ttl", v.cacheTTL, "Serve cached feeds younger than this without a request (0 disables)")
+	fs.BoolVar(&v.cacheParsed, "cache-parsed", v.cacheParsed, "Cache parsed items by feed content so an unchanged feed isn't parsed again")
+	fs.BoolVar(&v.revalidate, "revalidate", v.revalidate, "Check cached feeds with a conditional request even within the TTL")
+	fs.BoolVar(&v.noStale, "no-stale-fallback", v.noStale, "Fail instead of showing the cached copy when a fresh feed won't parse")
+	fs.BoolVar(&v.offline, "offline", v.offline, "Never use the network: serve feeds from the cache or file:// URLs only")
+	fs.BoolVar(&v.followNext, "follow-next", v.followNext, "Follow rel=\"next\" links to read paginated feeds")
+	fs.IntVar(&v.maxPages, "max-pages", v.maxPages, "Most pages to read per feed with -follow-next")
+	fs.Var(&v.staleAfter, "stale-after", "Warn when a feed's lastBuildDate is older than this, e.g. 72h or 3d (0 disables)")
+	fs.BoolVar(&v.quiet, "quiet", v.quiet, "Don't print warnings")
+	fs.BoolVar(&v.trace, "trace", v.trace, "Log DNS, connect, TLS and first-byte timings of each request to stderr")
+	fs.StringVar(&v.now, "now", v.now, "Pretend the current time is this RFC 3339 time, for reproducible output")
+
+	fs.StringVar(&v.dumpRaw, "dump-raw", v.dumpRaw, "Save the fetched feed body to this file before parsing")
+	fs.StringVar(&v.feedFormat, "feed-format", v.feedFormat, "Feed body format: auto|rss|json (JSON Feed)")
+	fs.IntVar(&v.maxTitle, "max-title-length", v.maxTitle, "Shorten feed titles to this many columns before parsing them (0 disables)")
+	fs.StringVar(&v.releasedPhrases, "released-phrases", v.releasedPhrases, "Comma-separated phrases stripped from the end of titles")
+	fs.StringVar(&v.notesPhrase, "notes-phrase", v.notesPhrase, "Phrase in descriptions after which the release notes start")
+	fs.BoolVar(&v.sortDevices, "sort-devices", v.sortDevices, "Order multi-device fields: iPhone, iPad, Mac, then other families, numbers in numeric order")
+	fs.StringVar(&v.preRelease, "prerelease-keywords", v.preRelease, "Comma-separated title keywords marking pre-releases, as word or word=rc for near-final builds")
+	fs.Var(&v.titleReplace, "title-replace", "Replace text in feed titles before parsing, as old=new (repeatable, applied in order)")
+	fs.Var(&v.titleRegex, "title-regex", "Like -title-replace with a regular expression, as pattern=replacement (applied after -title-replace)")
+	fs.BoolVar(&v.preserveWS, "preserve-whitespace", v.preserveWS, "Keep the original whitespace and line breaks of descriptions in JSON output")
+
+	fs.StringVar(&v.color, "color", v.color, "Color output: auto|always|never")
+	fs.StringVar(&v.color, "C", v.color, "Color output: auto|always|never (shorthand)")
+
+	fs.StringVar(&v.configPath, "config", v.configPath, "JSON config file (default "+defaultConfigPath()+")")
+	fs.BoolVar(&v.dumpConfig, "dump-config", v.dumpConfig, "Print the effective configuration as a config file and exit")
+
+	fs.BoolVar(&v.check, "check", v.check, "Only check that every feed answers with a 2xx status, then exit")
+	fs.BoolVar(&v.verbose, "verbose", v.verbose, "Report successful checks, changed items in watch, and a timing summary")
+	fs.BoolVar(&v.printSchema, "print-schema", v.printSchema, "Print the JSON Schema of -format json output and exit")
+	fs.BoolVar(&v.stripANSI, "strip-ansi", v.stripANSI, "Copy stdin to stdout without ANSI escape sequences and exit")
+	fs.BoolVar(&v.audit, "audit", v.audit, "Report how often each title-parsing heuristic applied across the feeds, then exit")
+}
+
+func addCommandFlags(fs *flag.FlagSet, name string, v *flagValues) {
+	if name == "diff" {
+		fs.StringVar(&v.format, "format", v.format, "Output format: table|json")
+		return
+	}
+	if name == "doctor" {
 		return
 	}
 
-	enableColor := shouldEnableColor(cfg.Color)
-	renderTable(filtered, enableColor, os.Stdout)
+	fs.IntVar(&v.limit, "limit", v.limit, "Number of entries to show")
+	fs.IntVar(&v.limit, "l", v.limit, "Number of entries to show (shorthand)")
+
+	fs.Var(&v.contains, "contains", "Substring filter on title; repeat to require several")
+	fs.Var(&v.contains, "c", "Substring filter on title (shorthand)")
+	fs.Var(&v.exclude, "exclude", "Hide items whose title contains this; repeatable")
+	fs.BoolVar(&v.caseSensitive, "case-sensitive", v.caseSensitive, "Match -contains and -exclude with exact case")
+	fs.BoolVar(&v.hasNotes, "has-notes", v.hasNotes, "Only show items with release notes")
+	fs.BoolVar(&v.hasNoNotes, "has-no-notes", v.hasNoNotes, "Only show items without release notes")
+
+	if name != "watch" {
+		fs.BoolVar(&v.porcelain, "porcelain", v.porcelain, "Stable tab-separated output for scripts")
+		fs.StringVar(&v.format, "format", v.format, "Output format: table|json|badge|histogram|env|rss|html")
+		fs.StringVar(&v.output, "output", v.output, "Write the output to this file instead of stdout (color auto means off)")
+		fs.StringVar(&v.outputDir, "output-dir", v.outputDir, "Write timeline.<ext> files for each of -formats into this directory instead of stdout")
+		fs.StringVar(&v.formats, "formats", v.formats, "Comma-separated formats for -output-dir: json,rss,html")
+		fs.BoolVar(&v.showBuild, "show-build", v.showBuild, "Include the build in badge output")
+		fs.BoolVar(&v.showFeedInfo, "show-feed-info", v.showFeedInfo, "Show each feed's title and last build date; wraps JSON items in an object")
+		fs.BoolVar(&v.jsonArray, "json-array", v.jsonArray, "Wrap JSON output in an array; -json-array=false writes one object per line")
+		fs.BoolVar(&v.summary, "platform-summary", v.summary, "Show one line per platform with its latest release instead of the table")
+		fs.BoolVar(&v.includePre, "include-prerelease", v.includePre, "Let betas and release candidates count as the latest in -platform-summary")
+		fs.BoolVar(&v.failEmpty, "fail-empty", v.failEmpty, "Exit with status 6 when no items match")
+		fs.StringVar(&v.stateFile, "state-file", v.stateFile, "File recording the newest item shown, updated after each run")
+		fs.BoolVar(&v.markNew, "mark-new", v.markNew, "Mark items newer than -state-file with '*'")
+		fs.BoolVar(&v.sinceLastRun, "since-last-run", v.sinceLastRun, "Only show items newer than -state-file records")
+	}
+
+	fs.StringVar(&v.fields, "fields", v.fields, "Comma-separated table columns: "+strings.Join(knownColumnKeys(), ","))
+	fs.StringVar(&v.columns, "columns", v.columns, "auto: pick the columns for the terminal width instead of -fields")
+	fs.BoolVar(&v.rawTitle, "raw-title", v.rawTitle, "Add the original feed title as a column")
+
+	fs.StringVar(&v.emptyMsg, "empty-message", v.emptyMsg, "Line to print instead of the table when no items match")
+
+	fs.StringVar(&v.platforms, "platform", v.platforms, "Comma-separated platform keys to show (e.g. ios,macos)")
+	fs.Var(&v.maxAge, "max-age", "Hide items published longer ago than this, e.g. 36h, 30d, 1d12h or 2w (0 disables)")
+	fs.StringVar(&v.sinceBuild, "new-since-build", v.sinceBuild, "Only show builds from trains newer than this, e.g. 21A or ios=21A,macos=23B")
+	fs.StringVar(&v.minVersion, "min-version", v.minVersion, "Only show versions at least this, e.g. 17 or ios=17,macos=14")
+	fs.BoolVar(&v.foldPre, "fold-prerelease", v.foldPre, "Hide betas and release candidates of versions whose final release is listed")
+	fs.IntVar(&v.perPlatform, "limit-per-platform", v.perPlatform, "Entries to show per platform not capped in the config file (0 disables)")
+	fs.StringVar(&v.sortBy, "sort", v.sortBy, "Sort order: date|platform")
+	fs.StringVar(&v.pin, "pin", v.pin, "Comma-separated platform keys to always list first (e.g. ios,macos)")
+	fs.StringVar(&v.groupBy, "group-by", v.groupBy, "Group rows or histogram bars by day|week|platform (default: follows -sort)")
+	fs.StringVar(&v.platOrder, "platform-order", v.platOrder, "Comma-separated platform keys for platform ordering (default "+strings.Join(defaultPlatformOrder, ",")+")")
+	fs.StringVar(&v.normVer, "normalize-version", v.normVer, "Version display: off|minor (17 -> 17.0)|trim (17.0 -> 17)")
+	fs.StringVar(&v.notesPolicy, "collapse-notes", v.notesPolicy, "Device column: device-only|notes-only|device-then-notes|notes-then-device")
+	fs.IntVar(&v.minDevice, "min-device-len", v.minDevice, "Show notes instead of devices shorter than this (0 keeps all devices)")
+	fs.StringVar(&v.expected, "expected-feed", v.expected, "Feed of expected releases to merge in")
+	fs.BoolVar(&v.strict, "strict-platforms", v.strict, "Fail when an item's platform is not recognized")
+
+	fs.BoolVar(&v.ascii, "ascii-stripe", v.ascii, "Use '|' instead of the unicode block for the platform stripe")
+	fs.IntVar(&v.indent, "indent", v.indent, "Spaces before each table row")
+	fs.BoolVar(&v.legend, "legend", v.legend, "Print the platform colors below the table")
+	fs.BoolVar(&v.noTruncate, "no-truncate", v.noTruncate, "Print the last column in full and let the terminal wrap it")
+	fs.BoolVar(&v.padToWidth, "pad-to-width", v.padToWidth, "Pad every table line with spaces to the full width")
+	fs.BoolVar(&v.collapseDups, "collapse-duplicates", v.collapseDups, "Draw consecutive identical rows as one with a count")
+	fs.StringVar(&v.ellipsis, "ellipsis", v.ellipsis, "Mark for text cut to fit its column")
+	fs.BoolVar(&v.noEllipsis, "no-ellipsis", v.noEllipsis, "Cut text to fit its column without a mark")
+	fs.BoolVar(&v.buildFirst, "build-first", v.buildFirst, "Show the build before the version, as in 21B74 (17.1)")
+	fs.BoolVar(&v.hyperlinks, "hyperlinks", v.hyperlinks, "Make the link column a clickable OSC 8 hyperlink")
+	fs.BoolVar(&v.fitHeight, "fit-height", v.fitHeight, "Show only as many rows as fit the terminal's height")
+	fs.StringVar(&v.divider, "divider", v.divider, "Group divider style: dashes|rule|header|bold")
+	fs.Var(fs.Lookup("divider").Value, "group-header-style", "Alias for -divider")
+	fs.BoolVar(&v.compactDates, "compact-dates", v.compactDates, "Show only the time of day under day dividers")
+	fs.StringVar(&v.emptyNotes, "group-empty-notes", v.emptyNotes, "Empty device/notes cells: blank|placeholder|hide (hide drops the column when every row is empty)")
+	fs.StringVar(&v.placeholder, "notes-placeholder", v.placeholder, "Text for empty device/notes cells with -group-empty-notes placeholder")
+	fs.BoolVar(&v.shortPlat, "short-platform", v.shortPlat, "Show platforms as three-letter codes (iOS, iPd, mac, ...)")
+	fs.BoolVar(&v.splitBuild, "split-version-build", v.splitBuild, "Show the build in its own column instead of after the version")
+	fs.BoolVar(&v.markSecurity, "mark-security", v.markSecurity, "Add a column marking releases whose notes mention security fixes or CVEs")
+	fs.BoolVar(&v.deviceCount, "device-count", v.deviceCount, "Add a column with the number of devices each release is for")
+	fs.StringVar(&v.locale, "locale", v.locale, "Language of table headers and platform labels: "+strings.Join(knownLocales(), "|")+" or one from the config file")
+	fs.BoolVar(&v.noSanitize, "no-sanitize", v.noSanitize, "Print control characters from feeds as they are instead of escaping them (trusted feeds only)")
+	fs.Var(&v.highlightAge, "highlight-age", "Highlight items published within this long, e.g. 24h or 1d12h (0 disables)")
+	fs.BoolVar(&v.dimOld, "dim-old", v.dimOld, "Fade rows older than a week, and more so past 30 days")
+	fs.IntVar(&v.gap, "gap", v.gap, "Spaces between table columns (one more before the device column)")
+
+	if name == "watch" {
+		fs.DurationVar(&v.interval, "interval", v.interval, "Polling interval")
+		fs.DurationVar(&v.interval, "i", v.interval, "Polling interval (shorthand)")
+		fs.BoolVar(&v.refreshSig, "refresh-on-signal", v.refreshSig, "Refresh immediately on SIGUSR1")
+	}
 }
 
+// parseFlags reads the shared flags, an optional command and the flags of
+// that command. Without a command the arguments are parsed as "list", so
+// invocations like "ipsw-timeline -l 5" keep working.
 func parseFlags() Config {
-	flagSet := flag.CommandLine
+	prog := os.Args[0]
+	args := os.Args[1:]
+	v := defaultFlagValues()
+
+	name := "list"
+	top := flag.NewFlagSet(prog, flag.ContinueOnError)
+	top.SetOutput(io.Discard)
+	addSharedFlags(top, &v)
+	topErr := top.Parse(args)
+	if topErr != nil {
+		v = defaultFlagValues()
+	} else {
+		rest := top.Args()
+		if len(rest) > 0 && isCommand(rest[0]) {
+			name = rest[0]
+			rest = rest[1:]
+		}
+		args = rest
+	}
 
-	feedURL := flagSet.String("feed-url", defaultFeedURL, "RSS feed URL")
-	flagSet.StringVar(feedURL, "f", defaultFeedURL, "RSS feed URL (shorthand)")
+	flagSet := flag.NewFlagSet(prog, flag.ExitOnError)
+	addSharedFlags(flagSet, &v)
+	addCommandFlags(flagSet, name, &v)
+	flagSet.Usage = func() {
+		out := flagSet.Output()
+		fmt.Fprintf(out, "Usage: %s [flags] [command] [flags]\n\nCommands:\n", prog)
+		for _, c := range commands {
+			fmt.Fprintf(out, "  %-8s %s\n", c.name, c.summary)
+		}
+		fmt.Fprintf(out, "\nFlags for %s:\n", name)
+		flagSet.PrintDefaults()
+	}
+	flagSet.Parse(args)
+	if topErr != nil && flagSet.NArg() > 0 && isCommand(flagSet.Arg(0)) {
+		// A flag of the command came before it, so the arguments were read
+		// as "list"; the top-level error is the one that explains why.
+		fmt.Fprintf(os.Stderr, "%v: flags of %s go after the command\n", topErr, flagSet.Arg(0))
+		os.Exit(2)
+	}
 
-	limit := flagSet.Int("limit", defaultLimit, "Number of entries to show")
-	flagSet.IntVar(limit, "l", defaultLimit, "Number of entries to show (shorthand)")
+	configPath := strings.TrimSpace(v.configPath)
+	explicitConfig := configPath != ""
+	if !explicitConfig {
+		configPath = defaultConfigPath()
+	}
+	fileCfg, err := loadConfigFile(configPath, explicitConfig)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "config error:", err)
+		os.Exit(1)
+	}
+	if err := registerPlatforms(fileCfg.Platforms); err != nil {
+		fmt.Fprintf(os.Stderr, "config error: %s: %v\n", configPath, err)
+		os.Exit(1)
+	}
+	if err := applyConfigFlags(flagSet, fileCfg.Flags); err != nil {
+		fmt.Fprintf(os.Stderr, "config error: %s: %v\n", configPath, err)
+		os.Exit(1)
+	}
+	if err := selectLocale(v.locale, fileCfg.Messages); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
-	contains := flagSet.String("contains", "", "Case-insensitive substring filter on title")
-	flagSet.StringVar(contains, "c", "", "Case-insensitive substring filter on title (shorthand)")
+	cfg := Config{
+		Command:            name,
+		Feeds:              trimAll(v.feeds.values),
+		FeedLabels:         trimAll(v.labels.values),
+		SourcePriority:     splitList(v.priority),
+		DedupeBy:           strings.ToLower(strings.TrimSpace(v.dedupeBy)),
+		WarnDuplicateGUIDs: v.warnDupGUIDs,
+		Fields:             splitList(strings.ToLower(v.fields)),
+		Columns:            strings.ToLower(strings.TrimSpace(v.columns)),
+		Sort:               strings.ToLower(strings.TrimSpace(v.sortBy)),
+		GroupBy:            strings.ToLower(strings.TrimSpace(v.groupBy)),
+		PlatformOrder:      splitList(strings.ToLower(v.platOrder)),
+		Pin:                splitList(strings.ToLower(v.pin)),
+		Platforms:          splitList(strings.ToLower(v.platforms)),
+		ShowBuild:          v.showBuild,
+		EmptyMessage:       v.emptyMsg,
+		FailEmpty:          v.failEmpty,
+		StateFile:          strings.TrimSpace(v.stateFile),
+		MarkNew:            v.markNew,
+		SinceLastRun:       v.sinceLastRun,
+		StrictPlatforms:    v.strict,
+		ConfigPath:         configPath,
+		Check:              v.check,
+		Verbose:            v.verbose,
+		PrintSchema:        v.printSchema,
+		Audit:              v.audit,
+		StripANSI:          v.stripANSI,
+		Output:             strings.TrimSpace(v.output),
+		Limit:              v.limit,
+		Contains:           trimAll(v.contains.values),
+		Exclude:            trimAll(v.exclude.values),
+		CaseSensitive:      v.caseSensitive,
+		HasNotes:           v.hasNotes,
+		HasNoNotes:         v.hasNoNotes,
+		Timeout:            time.Duration(v.timeoutSec) * time.Second,
+		Retries:            v.retries,
+		AttemptTimeout:     v.attemptTO,
+		ConnectTimeout:     v.connectTO,
+		HeaderTimeout:      v.headerTO,
+		Deadline:           v.deadline,
+		NoJitter:           v.noJitter,
+		MaxIdleConns:       v.maxIdle,
+		IdleConnTimeout:    v.idleTime,
+		HTTP1:              v.http1,
+		CacheDir:           strings.TrimSpace(v.cacheDir),
+		CacheParsed:        v.cacheParsed,
+		CacheTTL:           v.cacheTTL,
+		Revalidate:         v.revalidate,
+		NoStaleFallback:    v.noStale,
+		Offline:            v.offline,
+		FollowNext:         v.followNext,
+		MaxPages:           v.maxPages,
+		DumpRaw:            strings.TrimSpace(v.dumpRaw),
+		Color:              strings.ToLower(strings.TrimSpace(v.color)),
+		Latest:             name == "latest",
+		Porcelain:          v.porcelain,
+		Format:             strings.ToLower(strings.TrimSpace(v.format)),
+		NormalizeVersion:   strings.ToLower(strings.TrimSpace(v.normVer)),
+		NotesPolicy:        strings.ToLower(strings.TrimSpace(v.notesPolicy)),
+		MaxTitleLen:        v.maxTitle,
+		FeedFormat:         strings.ToLower(strings.TrimSpace(v.feedFormat)),
+		ReleasedPhrases:    splitList(v.releasedPhrases),
+		NotesPhrase:        strings.TrimSpace(v.notesPhrase),
+		PreserveWhitespace: v.preserveWS,
+		SortDevices:        v.sortDevices,
+		MinDeviceLen:       v.minDevice,
+		ASCIIStripe:        v.ascii,
+		Indent:             v.indent,
+		Gap:                v.gap,
+		Legend:             v.legend,
+		HighlightAge:       time.Duration(v.highlightAge),
+		DimOld:             v.dimOld,
+		ShortPlatform:      v.shortPlat,
+		SplitVersionBuild:  v.splitBuild,
+		NoSanitize:         v.noSanitize,
+		MarkSecurity:       v.markSecurity,
+		DeviceCount:        v.deviceCount,
+		CompactDates:       v.compactDates,
+		Divider:            strings.ToLower(strings.TrimSpace(v.divider)),
+		EmptyNotes:         strings.ToLower(strings.TrimSpace(v.emptyNotes)),
+		NotesPlaceholder:   v.placeholder,
+		NoTruncate:         v.noTruncate,
+		Ellipsis:           v.ellipsis,
+		BuildFirst:         v.buildFirst,
+		PadToWidth:         v.padToWidth,
+		CollapseDuplicates: v.collapseDups,
+		Hyperlinks:         v.hyperlinks,
+		FitHeight:          v.fitHeight,
+		ShowFeedInfo:       v.showFeedInfo,
+		JSONArray:          v.jsonArray,
+		StaleAfter:         time.Duration(v.staleAfter),
+		Quiet:              v.quiet,
+		Trace:              v.trace,
+		MaxAge:             time.Duration(v.maxAge),
+		FoldPreRelease:     v.foldPre,
+		OutputDir:          v.outputDir,
+		Formats:            splitList(strings.ToLower(v.formats)),
+		PlatformSummary:    v.summary,
+		IncludePreRelease:  v.includePre,
+		ExpectedFeed:       strings.TrimSpace(v.expected),
+		Interval:           v.interval,
+		RefreshOnSignal:    v.refreshSig,
+	}
 
-	timeoutSec := flagSet.Int("timeout", defaultTimeout, "HTTP timeout in seconds")
-	flagSet.IntVar(timeoutSec, "t", defaultTimeout, "HTTP timeout in seconds (shorthand)")
+	if len(fileCfg.PlatformLimits) > 0 || v.perPlatform > 0 {
+		cfg.PlatformLimits = make(map[string]int)
+		for key, n := range fileCfg.PlatformLimits {
+			if n < 0 {
+				fmt.Fprintf(os.Stderr, "config error: %s: platformLimits[%q] cannot be negative\n", configPath, key)
+				os.Exit(1)
+			}
+			cfg.PlatformLimits[strings.ToLower(strings.TrimSpace(key))] = n
+		}
+		if v.perPlatform > 0 {
+			cfg.PlatformLimits["*"] = v.perPlatform
+		}
+	}
+	if _, ok := dedupeKeys[cfg.DedupeBy]; !ok {
+		fmt.Fprintln(os.Stderr, "invalid deduplicate-by: use guid, link, or platform+version+build")
+		os.Exit(1)
+	}
+	if cfg.PlatformSummary && (cfg.Format != "table" || cfg.Porcelain) {
+		fmt.Fprintln(os.Stderr, "platform-summary only applies to the table format")
+		os.Exit(1)
+	}
+	if cfg.HasNotes && cfg.HasNoNotes {
+		fmt.Fprintln(os.Stderr, "has-notes and has-no-notes cannot be combined")
+		os.Exit(1)
+	}
+	if !cfg.JSONArray && cfg.ShowFeedInfo {
+		fmt.Fprintln(os.Stderr, "json-array=false cannot be combined with show-feed-info")
+		os.Exit(1)
+	}
+	if cfg.MaxAge < 0 {
+		fmt.Fprintln(os.Stderr, "max-age cannot be negative")
+		os.Exit(1)
+	}
+	if cfg.StaleAfter < 0 {
+		fmt.Fprintln(os.Stderr, "stale-after cannot be negative")
+		os.Exit(1)
+	}
+	quiet = cfg.Quiet
+	if v.noEllipsis {
+		cfg.Ellipsis = ""
+	}
+	if v.maxPages < 1 {
+		fmt.Fprintln(os.Stderr, "max-pages must be at least 1")
+		os.Exit(1)
+	}
+	if v.perPlatform < 0 {
+		fmt.Fprintln(os.Stderr, "limit-per-platform cannot be negative")
+		os.Exit(1)
+	}
 
-	color := flagSet.String("color", defaultColor, "Color output: auto|always|never")
-	flagSet.StringVar(color, "C", defaultColor, "Color output: auto|always|never (shorthand)")
+	keywords, err := parsePreReleaseKeywords(splitList(v.preRelease))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	cfg.PreReleaseKeywords = keywords
 
-	flagSet.Parse(os.Args[1:])
+	rewrites, err := parseTitleRewrites(v.titleReplace.values, v.titleRegex.values)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	cfg.TitleRewrites = rewrites
 
-	cfg := Config{
-		FeedURL:  strings.TrimSpace(*feedURL),
-		Limit:    *limit,
-		Contains: strings.TrimSpace(*contains),
-		Timeout:  time.Duration(*timeoutSec) * time.Second,
-		Color:    strings.ToLower(strings.TrimSpace(*color)),
+	if s := strings.TrimSpace(v.now); s != "" {
+		now, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid now %q: use an RFC 3339 time such as 2023-11-08T00:00:00Z\n", v.now)
+			os.Exit(1)
+		}
+		cfg.Now = now
+	}
+
+	trains, err := parseTrainFilters(splitList(v.sinceBuild))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	cfg.NewSinceBuild = trains
+
+	mins, err := parseMinVersions(splitList(v.minVersion))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	cfg.MinVersions = mins
+
+	for _, feedURL := range cfg.Feeds {
+		if feedURL == "" {
+			fmt.Fprintln(os.Stderr, "feed-url cannot be empty")
+			os.Exit(1)
+		}
+	}
+
+	if len(cfg.FeedLabels) > len(cfg.Feeds) {
+		fmt.Fprintln(os.Stderr, "more feed-label values than feed-url values")
+		os.Exit(1)
+	}
+
+	switch cfg.Columns {
+	case "":
+	case "auto":
+		if len(cfg.Fields) > 0 || v.rawTitle {
+			fmt.Fprintln(os.Stderr, "columns auto cannot be combined with -fields or -raw-title")
+			os.Exit(1)
+		}
+	default:
+		fmt.Fprintln(os.Stderr, "invalid columns: use auto, or -fields to choose columns yourself")
+		os.Exit(1)
+	}
+	if v.rawTitle && !slices.Contains(cfg.Fields, "title") {
+		if len(cfg.Fields) == 0 {
+			cfg.Fields = slices.Clone(defaultFields)
+		}
+		cfg.Fields = append(cfg.Fields, "title")
+	}
+	for _, field := range cfg.Fields {
+		if _, ok := knownColumns[field]; !ok {
+			fmt.Fprintf(os.Stderr, "unknown field %q: use %s\n", field, strings.Join(knownColumnKeys(), ", "))
+			os.Exit(1)
+		}
+	}
+
+	maxSize, err := parseByteSize(v.maxSize)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid max-feed-size: %v\n", err)
+		os.Exit(1)
 	}
+	cfg.MaxFeedSize = maxSize
 
-	if cfg.FeedURL == "" {
-		fmt.Fprintln(os.Stderr, "feed-url cannot be empty")
+	if cfg.Retries < 0 || cfg.AttemptTimeout < 0 || cfg.Deadline < 0 || cfg.ConnectTimeout < 0 || cfg.HeaderTimeout < 0 {
+		fmt.Fprintln(os.Stderr, "retries, per-attempt-timeout, connect-timeout, header-timeout and deadline cannot be negative")
+		os.Exit(1)
+	}
+
+	if cfg.CacheTTL < 0 {
+		fmt.Fprintln(os.Stderr, "cache-ttl cannot be negative")
+		os.Exit(1)
+	}
+	if cfg.MarkNew && cfg.StateFile == "" {
+		fmt.Fprintln(os.Stderr, "-mark-new requires -state-file")
+		os.Exit(1)
+	}
+	if cfg.SinceLastRun && cfg.StateFile == "" {
+		fmt.Fprintln(os.Stderr, "-since-last-run requires -state-file")
+		os.Exit(1)
+	}
+	if (cfg.CacheTTL > 0 || cfg.Revalidate) && cfg.CacheDir == "" {
+		fmt.Fprintln(os.Stderr, "no cache directory available: set -cache-dir")
+		os.Exit(1)
+	}
+
+	if cfg.RefreshOnSignal && len(refreshSignals()) == 0 {
+		fmt.Fprintln(os.Stderr, "-refresh-on-signal is not supported on this platform")
+		os.Exit(1)
+	}
+
+	if cfg.HighlightAge < 0 {
+		fmt.Fprintln(os.Stderr, "highlight-age cannot be negative")
+		os.Exit(1)
+	}
+
+	if cfg.Indent < 0 || cfg.Gap < 0 {
+		fmt.Fprintln(os.Stderr, "indent and gap cannot be negative")
+		os.Exit(1)
+	}
+
+	if cfg.MaxIdleConns < 0 || cfg.IdleConnTimeout < 0 {
+		fmt.Fprintln(os.Stderr, "max-idle-conns and idle-conn-timeout cannot be negative")
+		os.Exit(1)
+	}
+
+	for _, f := range cfg.Formats {
+		if _, ok := exportFormats[f]; !ok {
+			fmt.Fprintln(os.Stderr, "invalid formats: use json, rss, or html")
+			os.Exit(1)
+		}
+	}
+	if cfg.Output != "" && cfg.OutputDir != "" {
+		fmt.Fprintln(os.Stderr, "use either -output or -output-dir")
+		os.Exit(1)
+	}
+	if cfg.Output != "" && cfg.Color == "auto" {
+		// A file is never a terminal, whatever stdout is.
+		cfg.Color = "never"
+	}
+	if cfg.OutputDir != "" && len(cfg.Formats) == 0 {
+		fmt.Fprintln(os.Stderr, "output-dir needs at least one of -formats")
 		os.Exit(1)
 	}
 
@@ -159,83 +1565,410 @@ func parseFlags() Config {
 		os.Exit(1)
 	}
 
+	switch cfg.Format {
+	case "table", "json", "badge", "histogram", "env", "rss", "html":
+		if name == "diff" && cfg.Format != "table" && cfg.Format != "json" {
+			fmt.Fprintln(os.Stderr, "invalid format for diff: use table or json")
+			os.Exit(1)
+		}
+	default:
+		fmt.Fprintln(os.Stderr, "invalid format: use table, json, badge, histogram, env, rss, or html")
+		os.Exit(1)
+	}
+
+	switch cfg.FeedFormat {
+	case "auto", "rss", "json":
+	default:
+		fmt.Fprintln(os.Stderr, "invalid feed-format: use auto, rss, or json")
+		os.Exit(1)
+	}
+
+	switch cfg.EmptyNotes {
+	case "blank", "placeholder", "hide":
+	default:
+		fmt.Fprintln(os.Stderr, "invalid group-empty-notes: use blank, placeholder, or hide")
+		os.Exit(1)
+	}
+
+	switch cfg.Divider {
+	case "dashes", "rule", "header", "bold":
+	default:
+		fmt.Fprintln(os.Stderr, "invalid divider: use dashes, rule, header, or bold")
+		os.Exit(1)
+	}
+
+	switch cfg.GroupBy {
+	case "", "day", "week", "platform":
+	default:
+		fmt.Fprintln(os.Stderr, "invalid group-by: use day, week, or platform")
+		os.Exit(1)
+	}
+
+	switch cfg.Sort {
+	case "date", "platform":
+	default:
+		fmt.Fprintln(os.Stderr, "invalid sort: use date or platform")
+		os.Exit(1)
+	}
+
+	switch cfg.NormalizeVersion {
+	case "off", "minor", "trim":
+	default:
+		fmt.Fprintln(os.Stderr, "invalid normalize-version: use off, minor, or trim")
+		os.Exit(1)
+	}
+
+	switch cfg.NotesPolicy {
+	case "device-only", "notes-only", "device-then-notes", "notes-then-device":
+	default:
+		fmt.Fprintln(os.Stderr, "invalid collapse-notes: use device-only, notes-only, device-then-notes, or notes-then-device")
+		os.Exit(1)
+	}
+	if cfg.MaxTitleLen < 0 {
+		fmt.Fprintln(os.Stderr, "max-title-length cannot be negative")
+		os.Exit(1)
+	}
+	if cfg.MinDeviceLen < 0 {
+		fmt.Fprintln(os.Stderr, "min-device-len cannot be negative")
+		os.Exit(1)
+	}
+
+	resolve := func(feed string) string {
+		url, err := resolveFeedAlias(feed, fileCfg.FeedAliases)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return url
+	}
+	for i, feed := range cfg.Feeds {
+		cfg.Feeds[i] = resolve(feed)
+	}
+	if cfg.ExpectedFeed != "" {
+		cfg.ExpectedFeed = resolve(cfg.ExpectedFeed)
+	}
+
+	positional := flagSet.Args()
+	switch name {
+	case "diff":
+		if len(positional) < 1 || len(positional) > 2 {
+			fmt.Fprintln(os.Stderr, "diff expects OLD [NEW] feeds")
+			os.Exit(1)
+		}
+		cfg.DiffOld = feedSource(resolve(positional[0]))
+		cfg.DiffNew = cfg.Feeds[0]
+		if len(positional) == 2 {
+			cfg.DiffNew = feedSource(resolve(positional[1]))
+		}
+	default:
+		if len(positional) > 0 {
+			fmt.Fprintf(os.Stderr, "unknown command %q\n", positional[0])
+			os.Exit(1)
+		}
+	}
+
+	if name == "watch" && cfg.Interval <= 0 {
+		fmt.Fprintln(os.Stderr, "interval must be positive")
+		os.Exit(1)
+	}
+
+	if v.dumpConfig {
+		if err := writeConfig(os.Stdout, dumpConfig(flagSet, fileCfg)); err != nil {
+			fmt.Fprintln(os.Stderr, "write error:", err)
+			os.Exit(exitError)
+		}
+		os.Exit(0)
+	}
+
 	return cfg
 }
 
-func fetchFeed(url string, timeout time.Duration) ([]byte, error) {
-	client := &http.Client{Timeout: timeout}
-	req, err := http.NewRequest(http.MethodGet, url, nil)
-	if err != nil {
-		return nil, err
+func defaultFlagValues() flagValues {
+	return flagValues{
+		feeds:           stringList{values: []string{defaultFeedURL}},
+		timeoutSec:      defaultTimeout,
+		maxSize:         defaultMaxFeedSize,
+		maxIdle:         defaultMaxIdleConns,
+		idleTime:        defaultIdleConnTimeout,
+		dedupeBy:        "guid",
+		maxPages:        defaultMaxPages,
+		cacheDir:        defaultCacheDir(),
+		color:           defaultColor,
+		limit:           defaultLimit,
+		format:          defaultFormat,
+		indent:          defaultIndent,
+		gap:             defaultGap,
+		divider:         "dashes",
+		emptyNotes:      "blank",
+		placeholder:     "—",
+		ellipsis:        "…",
+		formats:         "json,rss,html",
+		jsonArray:       true,
+		locale:          "en",
+		feedFormat:      "auto",
+		normVer:         "off",
+		notesPolicy:     defaultNotesPolicy,
+		minDevice:       defaultMinDeviceLen,
+		releasedPhrases: defaultReleasedPhrases,
+		notesPhrase:     defaultNotesPhrase,
+		preRelease:      defaultPreRelease,
+		sortBy:          "date",
+		interval:        defaultInterval,
 	}
-	req.Header.Set("User-Agent", "ipsw-timeline-cli/1.0 (+https://ipsw.me)")
+}
 
-	resp, err := client.Do(req)
+// loadDir loads every .rss and .xml file in dir, in name order, as if each
+// had been given with -feed-url. Anything else is skipped with a warning.
+func loadDir(f *fetcher, dir string, norm normalizeOptions) ([]Item, error) {
+	entries, err := os.ReadDir(dir)
 	if err != nil {
-		return nil, err
+		return nil, &FetchError{URL: "file://" + dir, Err: err}
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	var items []Item
+	found := false
+	for _, e := range entries {
+		ext := strings.ToLower(filepath.Ext(e.Name()))
+		if !e.Type().IsRegular() || (ext != ".rss" && ext != ".xml") {
+			warnf("skipping %s: not an .rss or .xml file", filepath.Join(dir, e.Name()))
+			continue
+		}
+		found = true
+		fileItems, err := loadItems(f, "file://"+filepath.Join(dir, e.Name()), norm)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, fileItems...)
+	}
+	if !found {
+		return nil, &FetchError{URL: "file://" + dir, Err: errors.New("no .rss or .xml files in directory")}
 	}
+	return items, nil
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+// quiet silences warnf; parseFlags sets it from -quiet.
+var quiet bool
+
+// warnf reports a problem that doesn't stop the run, unless -quiet is set.
+func warnf(format string, args ...any) {
+	if quiet {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "warning: "+format+"\n", args...)
+}
+
+// formatAge renders d in whole days, or whole hours under two days.
+func formatAge(d time.Duration) string {
+	if d >= 48*time.Hour {
+		return fmt.Sprintf("%d days", int(d/(24*time.Hour)))
+	}
+	return fmt.Sprintf("%dh", int(d/time.Hour))
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+func trimAll(values []string) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = strings.TrimSpace(v)
 	}
+	return out
+}
+
+// feedSource turns a bare path into a file:// URL so commands taking feed
+// arguments accept saved snapshots as well as URLs.
+func feedSource(arg string) string {
+	if strings.Contains(arg, "://") {
+		return arg
+	}
+	return "file://" + arg
+}
+
+// parseFeed decodes a feed body as RSS or JSON Feed. format is rss, json or
+// auto, which picks JSON when the body starts with '{' or '['.
+func parseFeed(data []byte, format string) ([]rawItem, error) {
+	page, err := parseFeedPage(data, format)
+	return page.items, err
+}
 
-	return body, nil
+// feedPage is one parsed page of a feed. next is the link to the following
+// page, if the feed is paginated.
+type feedPage struct {
+	feed  Feed
+	items []rawItem
+	next  string
 }
 
-func parseFeed(data []byte) ([]rawItem, error) {
+func parseFeedPage(data []byte, format string) (feedPage, error) {
+	if format == "json" || (format == "auto" && looksLikeJSON(data)) {
+		return parseJSONFeed(data)
+	}
+
+	// encoding/xml rejects a whole document over one bad byte, so repair
+	// it here; normalizeItem cleans the fields of other formats.
+	if !utf8.Valid(data) {
+		data = bytes.ToValidUTF8(data, []byte("\uFFFD"))
+	}
 	var rss rawRSS
 	if err := xml.Unmarshal(data, &rss); err != nil {
-		return nil, err
+		return feedPage{}, &ParseError{Err: err}
+	}
+	ch := rss.Channel
+	page := feedPage{items: ch.Items}
+	page.feed = Feed{
+		Title:       normalizeSpace(html.UnescapeString(stripTags(strings.ToValidUTF8(ch.Title, "\uFFFD")))),
+		Description: normalizeSpace(html.UnescapeString(stripTags(strings.ToValidUTF8(ch.Description, "\uFFFD")))),
+	}
+	if s := strings.TrimSpace(ch.LastBuildDate); s != "" {
+		if t := parsePubDate(s); dateKnown(t) {
+			page.feed.LastBuildDate = t
+		}
 	}
-	return rss.Channel.Items, nil
+	for _, l := range rss.Channel.Links {
+		if l.Rel == "next" {
+			page.next = strings.TrimSpace(l.Href)
+			break
+		}
+	}
+	return page, nil
 }
 
-func normalizeItem(r rawItem) Item {
+// normalizeOptions tune how feed bodies are parsed and how the raw items
+// are turned into Items.
+type normalizeOptions struct {
+	FeedFormat      string
+	MaxTitleLen     int
+	ReleasedPhrases []string
+	NotesPhrase     string
+	// PreserveWhitespace keeps the line structure of descriptions instead
+	// of collapsing runs of whitespace. Notes are always collapsed.
+	PreserveWhitespace bool
+	PreReleaseKeywords []preReleaseKeyword
+	TitleRewrites      []titleRewrite
+	// SortDevices orders multi-device fields by family, then naturally.
+	SortDevices bool
+	// BuildFirst makes DisplayVersion lead with the build (-build-first).
+	BuildFirst bool
+}
+
+func normalizeOptionsFor(cfg Config) normalizeOptions {
+	return normalizeOptions{
+		FeedFormat:         cfg.FeedFormat,
+		MaxTitleLen:        cfg.MaxTitleLen,
+		ReleasedPhrases:    cfg.ReleasedPhrases,
+		NotesPhrase:        cfg.NotesPhrase,
+		PreserveWhitespace: cfg.PreserveWhitespace,
+		PreReleaseKeywords: cfg.PreReleaseKeywords,
+		TitleRewrites:      cfg.TitleRewrites,
+		SortDevices:        cfg.SortDevices,
+		BuildFirst:         cfg.BuildFirst,
+	}
+}
+
+func normalizeItem(r rawItem, opts normalizeOptions) Item {
+	r = r.validUTF8()
 	pub := parsePubDate(r.PubDate)
-	title := strings.TrimSpace(r.Title)
-	title = cleanReleaseSuffix(title)
+	// Rewrites only change what is split; the item keeps the feed's title.
+	title := strings.TrimSpace(rewriteTitle(r.Title, opts.TitleRewrites))
+	title = cleanReleaseSuffix(title, opts.ReleasedPhrases)
+	title = limitTitle(title, opts.MaxTitleLen)
 
 	mainPart, device := splitDevice(title)
 	basePart, build := splitBuild(mainPart)
 	platformLabel, version := splitPlatformVersion(basePart)
-	platformKey := platformKeyForTitle(platformLabel)
+	platformKey, known := platformKeyForTitle(platformLabel)
 	canonicalLabel := platformLabelForKey(platformKey)
-	prerelease := detectPreRelease(title)
+	unknownPlatform := ""
+	if !known {
+		unknownPlatform = platformLabel
+	}
+	var stage, keyword string
+	if kw := detectPreRelease(title, opts.PreReleaseKeywords); kw != nil {
+		stage, keyword = kw.stage, kw.word
+	}
 
-	plainDesc := stripTags(r.Description)
-	plainDesc = html.UnescapeString(plainDesc)
-	plainDesc = normalizeSpace(plainDesc)
-	notes := notesFromDescription(plainDesc)
+	rawDesc := html.UnescapeString(stripTags(r.Description))
+	plainDesc := normalizeSpace(rawDesc)
+	notes := notesFromDescription(plainDesc, opts.NotesPhrase)
+	description := plainDesc
+	if opts.PreserveWhitespace {
+		description = strings.TrimSpace(rawDesc)
+	}
 
 	device = strings.TrimSpace(device)
-	deviceOrNotes := combineDeviceAndNotes(device, notes)
+	devices := splitDevices(device)
+	if opts.SortDevices && len(devices) > 1 {
+		sortDevices(devices)
+		device = strings.Join(devices, ", ")
+	}
+	deviceOrNotes := combineDeviceAndNotes(device, notes, defaultNotesPolicy, defaultMinDeviceLen)
 
 	return Item{
-		Title:          r.Title,
-		Link:           r.Link,
-		PubDate:        pub,
-		GUID:           r.GUID,
-		Description:    plainDesc,
-		PlatformKey:    platformKey,
-		PlatformLabel:  canonicalLabel,
-		Version:        version,
-		Build:          build,
-		DeviceOrNotes:  deviceOrNotes,
-		PreRelease:     prerelease,
-		RawDevice:      device,
-		Notes:          notes,
-		DisplayDate:    pub.UTC().Format("2006-01-02 15:04 UTC"),
-		DisplayVersion: buildVersion(version, build),
-	}
-}
-
-func buildVersion(version, build string) string {
+		Title:             r.Title,
+		Link:              r.Link,
+		PubDate:           pub,
+		GUID:              r.GUID,
+		Description:       description,
+		PlatformKey:       platformKey,
+		PlatformLabel:     canonicalLabel,
+		Version:           version,
+		Build:             build,
+		DeviceOrNotes:     deviceOrNotes,
+		PreRelease:        stage != "",
+		PreReleaseStage:   stage,
+		PreReleaseKeyword: keyword,
+		RawDevice:         device,
+		Devices:           devices,
+		Notes:             notes,
+		DisplayDate:       pub.UTC().Format("2006-01-02 15:04 UTC"),
+		DisplayVersion:    buildVersion(version, build, opts.BuildFirst),
+		Provenance:        provenanceReleased,
+		UnknownPlatform:   unknownPlatform,
+		SecurityContent:   hasSecurityContent(r.Title + " " + r.Description),
+	}
+}
+
+// securityPattern finds the signs of a release with security fixes: a CVE
+// identifier or the word "security".
+var securityPattern = regexp.MustCompile(`(?i)\bCVE-\d{4}-\d+\b|\bsecurity\b`)
+
+// hasSecurityContent guesses from feed text whether a release fixes
+// security issues. It can't see fixes the feed doesn't mention.
+func hasSecurityContent(text string) bool {
+	return securityPattern.MatchString(text)
+}
+
+// validUTF8 replaces invalid UTF-8 in every field with U+FFFD, so the
+// rune-based splitting, padding and truncation downstream see whole
+// characters.
+func (r rawItem) validUTF8() rawItem {
+	for _, s := range []*string{&r.Title, &r.Link, &r.PubDate, &r.GUID, &r.Description} {
+		*s = strings.ToValidUTF8(*s, "\uFFFD")
+	}
+	return r
+}
+
+// limitTitle shortens title to at most max columns, the last of them an
+// ellipsis, before it is split into fields. 0 leaves the title alone.
+func limitTitle(title string, max int) string {
+	if max <= 0 || displayWidth(title) <= max {
+		return title
+	}
+	return strings.TrimRightFunc(cut(title, max-1), unicode.IsSpace) + "…"
+}
+
+// buildVersion joins a version and its build as "17.1 (21B74)", or with
+// buildFirst (-build-first) as "21B74 (17.1)".
+func buildVersion(version, build string, buildFirst bool) string {
 	version = strings.TrimSpace(version)
 	build = strings.TrimSpace(build)
 	if version == "" {
@@ -244,22 +1977,55 @@ func buildVersion(version, build string) string {
 	if build == "" {
 		return version
 	}
+	if buildFirst {
+		return fmt.Sprintf("%s (%s)", build, version)
+	}
 	return fmt.Sprintf("%s (%s)", version, build)
 }
 
-func cleanReleaseSuffix(title string) string {
+// normalizeVersion canonicalizes the numeric part of a version for display.
+// "minor" pads a bare major to major.minor ("17" -> "17.0") and "trim" drops
+// trailing ".0" components after the major ("17.0.0" -> "17"). Anything
+// after the numeric part, such as " beta 2", is left untouched.
+func normalizeVersion(version, mode string) string {
+	end := 0
+	for end < len(version) && (version[end] == '.' || (version[end] >= '0' && version[end] <= '9')) {
+		end++
+	}
+	numeric := strings.TrimRight(version[:end], ".")
+	if numeric == "" {
+		return version
+	}
+	rest := version[len(numeric):]
+	parts := strings.Split(numeric, ".")
+
+	switch mode {
+	case "minor":
+		if len(parts) == 1 {
+			parts = append(parts, "0")
+		}
+	case "trim":
+		for len(parts) > 1 && parts[len(parts)-1] == "0" {
+			parts = parts[:len(parts)-1]
+		}
+	default:
+		return version
+	}
+	return strings.Join(parts, ".") + rest
+}
+
+// cleanReleaseSuffix strips the first matching phrase, with or without a
+// trailing period, from the end of title. Longer phrases are tried first so
+// "has been released" wins over "released".
+func cleanReleaseSuffix(title string, phrases []string) string {
 	t := strings.TrimSpace(title)
-	lower := strings.ToLower(t)
-	suffixes := []string{
-		" has been released.",
-		" has been released",
-		" released.",
-		" released",
-	}
-	for _, s := range suffixes {
-		if strings.HasSuffix(lower, s) {
-			t = strings.TrimSpace(t[:len(t)-len(s)])
-			break
+	phrases = slices.Clone(phrases)
+	sort.SliceStable(phrases, func(i, j int) bool { return len(phrases[i]) > len(phrases[j]) })
+	for _, p := range phrases {
+		for _, s := range []string{" " + p + ".", " " + p} {
+			if len(t) >= len(s) && strings.EqualFold(t[len(t)-len(s):], s) {
+				return strings.TrimSpace(t[:len(t)-len(s)])
+			}
 		}
 	}
 	return t
@@ -302,18 +2068,46 @@ func splitPlatformVersion(base string) (platform, version string) {
 	return platform, version
 }
 
-func detectPreRelease(title string) bool {
-	lower := strings.ToLower(title)
-	if strings.Contains(lower, "beta") {
-		return true
-	}
-	if strings.Contains(lower, "rc") {
-		return true
+// preReleaseKeyword is a word or phrase in a title that marks a
+// pre-release, and the stage it signals: "beta" for early builds or "rc"
+// for near-final ones such as release candidates and GM builds.
+type preReleaseKeyword struct {
+	word    string
+	stage   string
+	pattern *regexp.Regexp
+}
+
+// parsePreReleaseKeywords reads entries like "beta" or "gm=rc". Entries
+// without a stage are betas. Longer keywords are tried first, so "public
+// beta" wins over "beta".
+func parsePreReleaseKeywords(entries []string) ([]preReleaseKeyword, error) {
+	keywords := make([]preReleaseKeyword, 0, len(entries))
+	for _, e := range entries {
+		word, stage, _ := strings.Cut(e, "=")
+		word = strings.ToLower(strings.TrimSpace(word))
+		stage = strings.ToLower(strings.TrimSpace(stage))
+		if stage == "" {
+			stage = "beta"
+		}
+		if word == "" || (stage != "beta" && stage != "rc") {
+			return nil, fmt.Errorf("invalid pre-release keyword %q: use word or word=beta|rc", e)
+		}
+		pattern := regexp.MustCompile(`(?i)\b` + strings.ReplaceAll(regexp.QuoteMeta(word), " ", `\s+`) + `\b`)
+		keywords = append(keywords, preReleaseKeyword{word: word, stage: stage, pattern: pattern})
 	}
-	if strings.Contains(lower, "release candidate") {
-		return true
+	sort.SliceStable(keywords, func(i, j int) bool { return len(keywords[i].word) > len(keywords[j].word) })
+	return keywords, nil
+}
+
+// detectPreRelease returns the first keyword found in title as a whole word
+// or phrase, or nil for a final release.
+func detectPreRelease(title string, keywords []preReleaseKeyword) *preReleaseKeyword {
+	for i := range keywords {
+		if keywords[i].pattern.MatchString(title) {
+			return &keywords[i]
+		}
 	}
-	return false
+	return nil
 }
 
 func parsePubDate(s string) time.Time {
@@ -323,6 +2117,7 @@ func parsePubDate(s string) time.Time {
 		time.RFC850,
 		time.ANSIC,
 		"Mon, 2 Jan 2006 15:04:05 -0700",
+		time.RFC3339,
 	}
 	for _, layout := range layouts {
 		if t, err := time.Parse(layout, s); err == nil {
@@ -332,6 +2127,12 @@ func parsePubDate(s string) time.Time {
 	return time.Unix(0, 0)
 }
 
+// dateKnown reports whether t is a real date rather than the Unix epoch
+// parsePubDate falls back to.
+func dateKnown(t time.Time) bool {
+	return !t.Equal(time.Unix(0, 0))
+}
+
 func stripTags(s string) string {
 	var b strings.Builder
 	inTag := false
@@ -350,62 +2151,134 @@ func stripTags(s string) string {
 	return b.String()
 }
 
-func notesFromDescription(desc string) string {
-	lower := strings.ToLower(desc)
-	phrase := "has been released"
-	idx := strings.Index(lower, phrase)
-	if idx < 0 {
+// notesFromDescription returns the text after phrase, which marks where the
+// boilerplate of a description ends. The match ignores case.
+func notesFromDescription(desc, phrase string) string {
+	idx := indexFold(desc, phrase)
+	if idx < 0 || phrase == "" {
 		return ""
 	}
 	after := strings.TrimSpace(desc[idx+len(phrase):])
 	return normalizeSpace(after)
 }
 
+// indexFold is strings.Index with case folding. Unlike lowercasing both
+// strings first, it returns an offset that is valid in s.
+func indexFold(s, substr string) int {
+	for i := 0; i+len(substr) <= len(s); i++ {
+		if strings.EqualFold(s[i:i+len(substr)], substr) {
+			return i
+		}
+	}
+	return -1
+}
+
 func normalizeSpace(s string) string {
 	fields := strings.Fields(s)
 	return strings.Join(fields, " ")
 }
 
-func combineDeviceAndNotes(device, notes string) string {
+// combineDeviceAndNotes builds the device column text according to policy:
+// device-only, notes-only, device-then-notes or notes-then-device. A device
+// without any letter or digit is junk and treated as missing. In the two
+// combined policies a device shorter than minDeviceLen characters is also
+// dropped in favor of the notes when there are any; 0 keeps every device,
+// so short names like "Mac" or "TV" survive by default.
+func combineDeviceAndNotes(device, notes, policy string, minDeviceLen int) string {
 	device = normalizeSpace(device)
 	notes = normalizeSpace(notes)
-
-	if device == "" && notes == "" {
-		return ""
+	if !strings.ContainsFunc(device, isAlphanumeric) {
+		device = ""
 	}
-	if device == "" {
+
+	switch policy {
+	case "device-only":
+		return device
+	case "notes-only":
 		return notes
 	}
-	if len(device) < 4 && notes != "" {
+
+	if notes != "" && utf8.RuneCountInString(device) < minDeviceLen {
+		device = ""
+	}
+	if device == "" {
 		return notes
 	}
 	if notes == "" {
 		return device
 	}
+	if policy == "notes-then-device" {
+		return notes + " - " + device
+	}
 	return device + " - " + notes
 }
 
-func platformKeyForTitle(platform string) string {
-	base := strings.ReplaceAll(strings.ToLower(platform), " ", "")
+func isAlphanumeric(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// applyNotesPolicy recombines the device column of every item when the
+// policy or threshold differs from the defaults used by normalizeItem.
+func applyNotesPolicy(items []Item, policy string, minDeviceLen int) {
+	if policy == defaultNotesPolicy && minDeviceLen == defaultMinDeviceLen {
+		return
+	}
+	for i := range items {
+		items[i].DeviceOrNotes = combineDeviceAndNotes(items[i].RawDevice, items[i].Notes, policy, minDeviceLen)
+	}
+}
+
+// platformKeyForTitle maps the platform named in a title to its key. ok is
+// false when the name is not recognized and the key falls back to "other".
+func platformKeyForTitle(platform string) (key string, ok bool) {
+	base := platformName(platform)
+	if key, ok := customPlatformNames[base]; ok {
+		return key, true
+	}
 	switch base {
 	case "ios", "iphone":
-		return "ios"
+		return "ios", true
 	case "ipados", "ipad":
-		return "ipados"
+		return "ipados", true
 	case "macos", "mac":
-		return "macos"
+		return "macos", true
 	case "watchos", "watch":
-		return "watchos"
+		return "watchos", true
 	case "tvos", "audioos", "homepod", "appletv":
-		return "tvos"
+		return "tvos", true
 	case "visionos", "vision":
-		return "visionos"
+		return "visionos", true
 	default:
-		return "other"
+		return "other", false
+	}
+}
+
+// checkPlatforms fails with the distinct platform names that mapped to
+// "other", in the order they first appear.
+func checkPlatforms(items []Item) error {
+	var labels []string
+	seen := make(map[string]bool)
+	for _, it := range items {
+		if it.PlatformKey != "other" || seen[it.UnknownPlatform] {
+			continue
+		}
+		seen[it.UnknownPlatform] = true
+		labels = append(labels, it.UnknownPlatform)
+	}
+	if len(labels) == 0 {
+		return nil
 	}
+	return &UnknownPlatformError{Labels: labels}
 }
 
 func platformLabelForKey(key string) string {
+	if p, ok := customPlatforms[key]; ok && p.label != "" {
+		return p.label
+	}
+	return translate("platform."+key, englishPlatformLabel(key))
+}
+
+func englishPlatformLabel(key string) string {
 	switch key {
 	case "ios":
 		return "iOS"
@@ -419,25 +2292,310 @@ func platformLabelForKey(key string) string {
 		return "tvOS"
 	case "visionos":
 		return "visionOS"
+	case "other":
+		return "Other"
 	default:
+		if _, ok := customPlatforms[key]; ok {
+			return key
+		}
 		return "Other"
 	}
 }
 
-func filterItems(items []Item, contains string) []Item {
-	if strings.TrimSpace(contains) == "" {
+// platformCodeForKey is the three-character platform code shown with
+// -short-platform or when the terminal is too narrow for labels. Platforms
+// without a code use the start of their label.
+func platformCodeForKey(key string) string {
+	switch key {
+	case "ios":
+		return "iOS"
+	case "ipados":
+		return "iPd"
+	case "macos":
+		return "mac"
+	case "watchos":
+		return "wch"
+	case "tvos":
+		return "tv "
+	case "visionos":
+		return "vis"
+	case "other":
+		return "oth"
+	default:
+		return pad(cut(platformLabelForKey(key), 3), 3)
+	}
+}
+
+// filterItems keeps items whose title contains every one of contains and
+// none of exclude. Matching ignores case unless caseSensitive is set; empty
+// needles are ignored.
+func filterItems(items []Item, contains, exclude []string, caseSensitive bool) []Item {
+	fold := func(s string) string {
+		if caseSensitive {
+			return s
+		}
+		return strings.ToLower(s)
+	}
+	var want, skip []string
+	for _, s := range contains {
+		if s != "" {
+			want = append(want, fold(s))
+		}
+	}
+	for _, s := range exclude {
+		if s != "" {
+			skip = append(skip, fold(s))
+		}
+	}
+	if len(want) == 0 && len(skip) == 0 {
+		return items
+	}
+	var out []Item
+	for _, it := range items {
+		title := fold(it.Title)
+		matches := func(needle string) bool { return strings.Contains(title, needle) }
+		if slices.ContainsFunc(skip, matches) || !allMatch(want, matches) {
+			continue
+		}
+		out = append(out, it)
+	}
+	return out
+}
+
+func allMatch(needles []string, match func(string) bool) bool {
+	for _, n := range needles {
+		if !match(n) {
+			return false
+		}
+	}
+	return true
+}
+
+// filterNotes keeps items that have release notes when want is set, and
+// items without them otherwise. It looks at the notes themselves, whatever
+// -collapse-notes puts in the device column, and notes with no letters or
+// digits, such as the "." left after "has been released.", don't count.
+func filterNotes(items []Item, want bool) []Item {
+	var out []Item
+	for _, it := range items {
+		has := strings.IndexFunc(it.Notes, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) >= 0
+		if has == want {
+			out = append(out, it)
+		}
+	}
+	return out
+}
+
+// filterPlatforms keeps items whose platform key is in keys. An empty list
+// keeps everything.
+func filterPlatforms(items []Item, keys []string) []Item {
+	if len(keys) == 0 {
 		return items
 	}
-	needle := strings.ToLower(contains)
+	want := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		want[k] = true
+	}
 	var out []Item
 	for _, it := range items {
-		if strings.Contains(strings.ToLower(it.Title), needle) {
+		if want[it.PlatformKey] {
 			out = append(out, it)
 		}
 	}
 	return out
 }
 
+// defaultPlatformOrder is the product order used when sorting or grouping
+// by platform.
+var defaultPlatformOrder = []string{"ios", "ipados", "macos", "watchos", "tvos", "visionos"}
+
+// sortItems orders items in place. "date" is newest first; "platform" ranks
+// items by their position in order (defaultPlatformOrder when empty), with
+// unlisted platforms last in key order, and newest first within a platform.
+// Platforms listed in pin come first, in pin order, each keeping the usual
+// order within it.
+func sortItems(items []Item, by string, order []string, pin []string) {
+	pinRank := func(key string) int {
+		if i := slices.Index(pin, key); i >= 0 {
+			return i
+		}
+		return len(pin)
+	}
+
+	if by != "platform" {
+		sort.SliceStable(items, func(i, j int) bool {
+			if pi, pj := pinRank(items[i].PlatformKey), pinRank(items[j].PlatformKey); pi != pj {
+				return pi < pj
+			}
+			return items[i].PubDate.After(items[j].PubDate)
+		})
+		return
+	}
+
+	if len(order) == 0 {
+		order = defaultPlatformOrder
+	}
+	rank := make(map[string]int, len(order))
+	for i, key := range order {
+		rank[key] = i
+	}
+	rankOf := func(key string) int {
+		if r, ok := rank[key]; ok {
+			return r
+		}
+		return len(order)
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		if pi, pj := pinRank(items[i].PlatformKey), pinRank(items[j].PlatformKey); pi != pj {
+			return pi < pj
+		}
+		ri, rj := rankOf(items[i].PlatformKey), rankOf(items[j].PlatformKey)
+		if ri != rj {
+			return ri < rj
+		}
+		if items[i].PlatformKey != items[j].PlatformKey {
+			return items[i].PlatformKey < items[j].PlatformKey
+		}
+		return items[i].PubDate.After(items[j].PubDate)
+	})
+}
+
+// groupByForSort picks the table grouping that matches the sort order, so
+// dividers always separate contiguous runs of rows.
+func groupByForSort(by string) string {
+	if by == "platform" {
+		return "platform"
+	}
+	return "day"
+}
+
+func groupLabel(it Item, groupBy string) string {
+	if groupBy == "platform" {
+		key := it.PlatformKey
+		if key == "" {
+			key = "other"
+		}
+		return platformLabelForKey(key)
+	}
+	if groupBy == "week" {
+		year, week := it.PubDate.UTC().ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	}
+	return it.PubDate.UTC().Format("2006-01-02")
+}
+
+// limitPerPlatform keeps at most limits[key] items of each platform, in
+// their current order. The "*" entry applies to platforms without their own
+// entry; platforms with neither are not capped.
+func limitPerPlatform(items []Item, limits map[string]int) []Item {
+	if len(limits) == 0 {
+		return items
+	}
+	counts := make(map[string]int)
+	out := make([]Item, 0, len(items))
+	for _, it := range items {
+		key := it.PlatformKey
+		if key == "" {
+			key = "other"
+		}
+		limit, ok := limits[key]
+		if !ok {
+			limit, ok = limits["*"]
+		}
+		if ok && counts[key] >= limit {
+			continue
+		}
+		counts[key]++
+		out = append(out, it)
+	}
+	return out
+}
+
+// filterMaxAge drops items published more than maxAge before now (the real
+// time when now is zero), and items whose date couldn't be parsed. A
+// maxAge of 0 keeps everything.
+func filterMaxAge(items []Item, maxAge time.Duration, now time.Time) []Item {
+	if maxAge <= 0 {
+		return items
+	}
+	if now.IsZero() {
+		now = time.Now()
+	}
+	cutoff := now.Add(-maxAge)
+	var out []Item
+	for _, it := range items {
+		if !dateKnown(it.PubDate) || it.PubDate.Before(cutoff) {
+			continue
+		}
+		out = append(out, it)
+	}
+	return out
+}
+
+// releasedOnly drops expected items and, unless includePreRelease is set,
+// pre-releases.
+func releasedOnly(items []Item, includePreRelease bool) []Item {
+	var out []Item
+	for _, it := range items {
+		if it.Provenance == provenanceExpected || (it.PreRelease && !includePreRelease) {
+			continue
+		}
+		out = append(out, it)
+	}
+	return out
+}
+
+// foldPreReleases drops the pre-releases of every version whose final
+// release is among items, matching on platform and base version: "17.1
+// beta 4" and "17.1 RC" fold into "17.1", but not into "17.1.1". Versions
+// with only pre-releases so far are kept as they are.
+func foldPreReleases(items []Item) []Item {
+	finals := make(map[string]bool)
+	for _, it := range items {
+		if !it.PreRelease && it.Provenance != provenanceExpected {
+			finals[it.PlatformKey+" "+baseVersion(it.Version)] = true
+		}
+	}
+	var out []Item
+	for _, it := range items {
+		if it.PreRelease && finals[it.PlatformKey+" "+baseVersion(it.Version)] {
+			continue
+		}
+		out = append(out, it)
+	}
+	return out
+}
+
+// baseVersion is the version number at the start of version, without
+// trailing ".0" components, so "17.0 beta 2" and "17" both give "17".
+func baseVersion(version string) string {
+	fields := strings.Fields(version)
+	if len(fields) == 0 {
+		return ""
+	}
+	base := fields[0]
+	for strings.HasSuffix(base, ".0") {
+		base = strings.TrimSuffix(base, ".0")
+	}
+	return base
+}
+
+// latestPerPlatform keeps the first item seen for each platform, which is the
+// newest one when items are sorted by date.
+func latestPerPlatform(items []Item) []Item {
+	seen := make(map[string]bool)
+	var out []Item
+	for _, it := range items {
+		if seen[it.PlatformKey] {
+			continue
+		}
+		seen[it.PlatformKey] = true
+		out = append(out, it)
+	}
+	return out
+}
+
 func shouldEnableColor(mode string) bool {
 	switch mode {
 	case "always":
@@ -460,89 +2618,211 @@ func isTTY() bool {
 	return (info.Mode() & os.ModeCharDevice) != 0
 }
 
-func renderTable(items []Item, enableColor bool, out io.Writer) {
-	totalWidth := terminalWidth()
-	indent := 2
-	dateWidth := 20
-	stripeWidth := 1
-	platformWidth := 12
-	versionWidth := 24
-	gap := 2
-	deviceWidth := totalWidth - (indent + dateWidth + 1 + stripeWidth + 1 + platformWidth + 1 + versionWidth + gap)
-	if deviceWidth < 16 {
-		deviceWidth = 16
+// renderTableString returns the table renderTable would write, for callers
+// that want the text rather than a writer.
+func renderTableString(items []Item, opts renderOptions) string {
+	var b strings.Builder
+	renderTable(items, opts, &b)
+	return b.String()
+}
+
+func renderTable(items []Item, opts renderOptions, out io.Writer) {
+	if opts.Sanitize {
+		items = sanitizeItems(items)
+	}
+	var runs []int
+	if opts.CollapseRows {
+		items, runs = collapseDuplicates(items, opts.GroupBy)
+	}
+	if opts.MaxLines > 0 {
+		items = items[:rowsThatFit(items, opts, opts.MaxLines)]
+	}
+	totalWidth := opts.width()
+	indent := opts.Indent
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
 	}
 
-	color := colorizer{enabled: enableColor}
+	fields := opts.Fields
+	if opts.Columns == "auto" {
+		fields = autoFields(totalWidth, indent, opts.Gap)
+	}
+	if opts.DeviceCount {
+		fields = addField(fields, "devices", "version")
+	}
+	if opts.SplitVersionBuild {
+		fields = addField(fields, "build", "version")
+	}
+	if opts.MarkSecurity {
+		fields = addField(fields, "security", "version")
+	}
+	if opts.EmptyNotes == "hide" && !slices.ContainsFunc(items, hasNotes) {
+		fields = withoutField(fields, "device")
+	}
+	cols := columnsFor(fields)
+	if opts.Gutter {
+		cols = append([]column{changeColumn}, cols...)
+	}
+	for i, col := range cols {
+		switch {
+		case col.key == "platform" && opts.ShortPlatform:
+			cols[i] = col.short()
+		case col.key == "version" && opts.SplitVersionBuild:
+			cols[i] = col.short()
+			cols[i].shortAt = layoutFull
+		case col.key == "version" && opts.BuildFirst:
+			cols[i] = col.buildFirst()
+		case col.key == "date" && opts.compactDates():
+			cols[i].width = compactDateWidth
+			cols[i].shortAt = layoutFull
+		}
+	}
+	cols, layout := fitColumns(cols, totalWidth, indent, opts.Gap)
+	if len(cols) == 0 {
+		return
+	}
+	opts.Layout = layout
+	if last := len(cols) - 1; opts.NoTruncate && last >= 0 && cols[last].flex {
+		opts.Overflow = cols[last].key
+	}
+	widths := columnWidths(cols, totalWidth, indent, opts.Gap)
+	color := colorizer{enabled: opts.Color}
 
-	header := buildHeader(deviceWidth)
-	fmt.Fprintln(out, header)
-	fmt.Fprintln(out, strings.Repeat("-", len(header)))
+	writeLine := func(line string) {
+		if opts.PadToWidth {
+			line += strings.Repeat(" ", max(0, totalWidth-displayWidth(line)))
+		}
+		fmt.Fprintln(out, line)
+	}
+	header := buildHeader(cols, widths, indent, opts.Gap, opts.Ellipsis)
+	writeLine(header)
+	writeLine(strings.Repeat("-", displayWidth(header)))
 
 	var lastDate string
-	for _, it := range items {
-		day := it.PubDate.UTC().Format("2006-01-02")
+	for n, it := range items {
+		day := groupLabel(it, opts.GroupBy)
 		if day != lastDate {
 			lastDate = day
-			line := dayDivider(day, totalWidth)
-			fmt.Fprintln(out, line)
+			writeLine(dayDivider(day, platformColor(summaryKey(it)), totalWidth, opts, color))
 		}
 
-		dateField := pad(truncate(it.DisplayDate, dateWidth), dateWidth)
-		stripe := stripeChar(it.PlatformKey)
-		platformKey := it.PlatformKey
-		if platformKey == "" {
-			platformKey = "other"
+		row := color
+		if opts.DimOld {
+			row.fade = ageBand(it, opts.Now)
 		}
-		plabel := platformLabelForKey(platformKey)
-		platformField := pad(truncate(plabel, platformWidth), platformWidth)
-		versionText := buildVersion(it.Version, it.Build)
-		versionField := pad(truncate(versionText, versionWidth), versionWidth)
-		deviceField := pad(truncate(it.DeviceOrNotes, deviceWidth), deviceWidth)
+		row.tint = changeColor(it.Change)
+		var mark string
+		if runs != nil && runs[n] > 1 {
+			mark = runMark(runs[n], opts.ASCII)
+		}
+		// The run mark comes out of the last column, so the row still fits.
+		lastWidth := widths[len(widths)-1]
+		if over := rowWidth(cols, widths, indent, opts.Gap) + displayWidth(mark) - totalWidth; mark != "" && over > 0 {
+			lastWidth = max(1, lastWidth-over)
+		}
+		var b strings.Builder
+		b.WriteString(strings.Repeat(" ", indent))
+		for i, col := range cols {
+			if i > 0 {
+				b.WriteString(columnGap(col, opts.Gap))
+			}
+			width := widths[i]
+			if i == len(cols)-1 {
+				width = lastWidth
+			}
+			cell := col.cell(it, width, opts, row)
+			if (row.fade > 0 || row.tint != "") && !strings.Contains(cell, "\033") {
+				cell = row.wrap("39", cell)
+			}
+			b.WriteString(cell)
+		}
+		b.WriteString(mark)
+		writeLine(b.String())
+	}
 
-		stripeColored := stripe
-		platformColored := platformField
-		versionColored := versionField
-		deviceColored := deviceField
+	if opts.Legend {
+		writeLine("")
+		writeLine(legendLine(opts))
+	}
+}
 
-		colorCode := platformColor(platformKey)
-		if enableColor {
-			stripeColored = color.color(colorCode, stripe)
-			platformColored = color.color(colorCode, platformField)
-			versionColored = colorizeVersion(versionField, colorCode, it.PreRelease, color)
-			deviceColored = color.dim(deviceField)
+// legendKeys lists the built-in platforms in product order, then any
+// platforms added by the config file, then "other".
+func legendKeys() []string {
+	keys := append([]string(nil), defaultPlatformOrder...)
+	var custom []string
+	for key := range customPlatforms {
+		if !slices.Contains(keys, key) && key != "other" {
+			custom = append(custom, key)
 		}
+	}
+	sort.Strings(custom)
+	return append(append(keys, custom...), "other")
+}
 
-		fmt.Fprintf(out, "%s%s %s %s %s  %s\n",
-			strings.Repeat(" ", indent),
-			dateField,
-			stripeColored,
-			platformColored,
-			versionColored,
-			deviceColored,
-		)
+// legendLine lists each platform's stripe and label in its color, so the
+// stripe colors can be told apart. Without color it still lists the stripe
+// symbol next to each platform.
+func legendLine(opts renderOptions) string {
+	color := colorizer{enabled: opts.Color}
+	keys := legendKeys()
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		code := platformColor(key)
+		parts = append(parts, color.color(code, stripeChar(key, opts.ASCII))+" "+color.color(code, platformLabelForKey(key)))
 	}
+	return strings.Repeat(" ", opts.Indent) + "Legend: " + strings.Join(parts, "  ")
 }
 
-func buildHeader(deviceWidth int) string {
-	date := pad("Published", 20)
-	stripe := " "
-	platform := pad("Platform", 12)
-	version := pad("Version (Build)", 24)
-	device := pad("Device / Notes", deviceWidth)
-	return fmt.Sprintf("  %s %s %s %s  %s", date, stripe, platform, version, device)
+func buildHeader(cols []column, widths []int, indent, gap int, ellipsis string) string {
+	var b strings.Builder
+	b.WriteString(strings.Repeat(" ", indent))
+	for i, col := range cols {
+		if i > 0 {
+			b.WriteString(columnGap(col, gap))
+		}
+		b.WriteString(strings.Repeat(" ", col.lead))
+		header := headerText(col)
+		b.WriteString(pad(truncate(header, widths[i]-col.lead, ellipsis), widths[i]-col.lead))
+	}
+	return b.String()
 }
 
-func dayDivider(day string, totalWidth int) string {
+// dayDivider draws the line above each group of rows in opts.Divider
+// style: the label followed by dashes, the label centered in a full-width
+// box-drawing rule (dashes in ASCII mode), or the label on its own, bold
+// and underlined, or bold in the color of code, the platform of the
+// group's first row (under -sort platform, the group's own). The fill is
+// measured with displayWidth, so multibyte labels line up; a label wider
+// than the table is cut.
+func dayDivider(day, code string, totalWidth int, opts renderOptions, c colorizer) string {
 	prefix := " " + day + " "
-	dashes := totalWidth - len(prefix)
+	switch opts.Divider {
+	case "header":
+		return " " + c.wrap("1;4", day)
+	case "bold":
+		return " " + c.wrap("1;"+code, day)
+	}
+
+	dashes := totalWidth - displayWidth(prefix)
 	if dashes < 0 {
-		dashes = 0
+		return truncate(prefix, totalWidth, opts.Ellipsis)
+	}
+	if opts.Divider == "rule" {
+		fill := "─"
+		if opts.ASCII {
+			fill = "-"
+		}
+		left := dashes / 2
+		return strings.Repeat(fill, left) + prefix + strings.Repeat(fill, dashes-left)
 	}
 	return prefix + strings.Repeat("-", dashes)
 }
 
 func platformColor(key string) string {
+	if p, ok := customPlatforms[key]; ok && p.color != "" {
+		return p.color
+	}
 	switch key {
 	case "ios":
 		return "31"
@@ -550,18 +2830,39 @@ func platformColor(key string) string {
 		return "36"
 	case "macos":
 		return "32"
-	case "watchos", "tvos", "visionos", "other":
+	case "watchos":
 		return "35"
+	case "tvos":
+		return "34"
+	case "visionos":
+		return "33"
+	case "other":
+		return "90"
 	default:
-		return "35"
+		return hashedColors[fnvHash(key)%uint32(len(hashedColors))]
 	}
 }
 
+// hashedColors are the bright colors for platforms without a color of
+// their own, picked by key so each keeps its color from run to run.
+var hashedColors = []string{"91", "92", "93", "94", "95", "96"}
+
+func fnvHash(s string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	return h.Sum32()
+}
+
 func colorizeVersion(version string, colorCode string, prerelease bool, c colorizer) string {
 	if version == "" || !c.enabled {
 		return version
 	}
 
+	if c.fade > 0 || c.tint != "" {
+		// Bold digits would cancel the faint of -dim-old, and a tinted row
+		// is one color throughout.
+		return c.wrap(colorCode, version)
+	}
 	if prerelease {
 		return c.wrap("1;"+colorCode, version)
 	}
@@ -581,27 +2882,168 @@ func colorizeVersion(version string, colorCode string, prerelease bool, c colori
 	return b.String()
 }
 
-func stripeChar(platformKey string) string {
+func stripeChar(platformKey string, ascii bool) string {
 	if platformKey == "" {
 		platformKey = "other"
 	}
+	if ascii {
+		return "|"
+	}
 	return "▌"
 }
 
+// unicodeSupported reports whether the locale advertises UTF-8, checking
+// LC_ALL, LC_CTYPE and LANG in the order the C library does. When none of
+// them is set unicode is assumed, unless TERM names the dumb terminal.
+func unicodeSupported() bool {
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		v := os.Getenv(name)
+		if v == "" {
+			continue
+		}
+		v = strings.ToLower(v)
+		return strings.Contains(v, "utf-8") || strings.Contains(v, "utf8")
+	}
+	return os.Getenv("TERM") != "dumb"
+}
+
+// displayWidth is the number of terminal columns s takes, counted the same
+// way as pad and truncate: two for wide characters such as CJK and emoji,
+// none for combining marks, one for the rest, ignoring ANSI escape
+// sequences.
+func displayWidth(s string) int {
+	n := 0
+	for _, r := range stripANSI(s) {
+		n += runeWidth(r)
+	}
+	return n
+}
+
+// wideRanges are the East Asian wide and fullwidth blocks and the emoji
+// blocks terminals draw two columns wide.
+var wideRanges = [][2]rune{
+	{0x1100, 0x115f}, {0x2e80, 0x303e}, {0x3041, 0x33ff}, {0x3400, 0x4dbf},
+	{0x4e00, 0x9fff}, {0xa000, 0xa4cf}, {0xac00, 0xd7a3}, {0xf900, 0xfaff},
+	{0xfe30, 0xfe4f}, {0xff00, 0xff60}, {0xffe0, 0xffe6}, {0x1f300, 0x1f64f},
+	{0x1f680, 0x1f6ff}, {0x1f900, 0x1f9ff}, {0x1fa70, 0x1faff}, {0x20000, 0x3fffd},
+}
+
+func runeWidth(r rune) int {
+	switch {
+	case r == 0x200b || r == 0x200d || r >= 0xfe00 && r <= 0xfe0f,
+		unicode.In(r, unicode.Mn, unicode.Me):
+		return 0
+	}
+	for _, w := range wideRanges {
+		if r >= w[0] && r <= w[1] {
+			return 2
+		}
+	}
+	return 1
+}
+
 func pad(s string, width int) string {
-	runes := []rune(s)
-	if len(runes) >= width {
+	return s + strings.Repeat(" ", max(0, width-displayWidth(s)))
+}
+
+// truncate cuts s to width, ending it with ellipsis (-ellipsis) when
+// anything was cut. Widths too narrow for the ellipsis, or an empty one
+// (-no-ellipsis), get a plain cut.
+func truncate(s string, width int, ellipsis string) string {
+	if displayWidth(s) <= width {
 		return s
 	}
-	return s + strings.Repeat(" ", width-len(runes))
+	if mark := displayWidth(ellipsis); mark > 0 && width > mark {
+		return cut(s, width-mark) + ellipsis
+	}
+	return cut(s, width)
 }
 
-func truncate(s string, width int) string {
-	runes := []rune(s)
-	if len(runes) <= width {
-		return s
+// cut is truncate without the ellipsis, for fixed-width codes. A wide
+// character that would straddle width is dropped, so the result may be a
+// column short.
+func cut(s string, width int) string {
+	n := 0
+	for i, r := range s {
+		if n += runeWidth(r); n > width {
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// rowsThatFit counts how many leading items renderTable can draw within
+// lines, leaving room for the header, the group dividers, the legend and
+// the shell prompt after the table.
+func rowsThatFit(items []Item, opts renderOptions, lines int) int {
+	budget := lines - 3
+	if opts.Legend {
+		budget -= 2
+	}
+	var lastDate string
+	for i, it := range items {
+		need := 1
+		if day := groupLabel(it, opts.GroupBy); day != lastDate {
+			lastDate = day
+			need++
+		}
+		if need > budget {
+			return i
+		}
+		budget -= need
+	}
+	return len(items)
+}
+
+// collapseDuplicates drops each row that shows the same platform, version,
+// build and device or notes as the row above it in the same group, and
+// returns the remaining rows with the length of the run each one stands
+// for. Unlike -deduplicate-by this only removes visual repetition: rows
+// that aren't adjacent are kept even when identical.
+func collapseDuplicates(items []Item, groupBy string) ([]Item, []int) {
+	var kept []Item
+	var runs []int
+	var lastKey string
+	for _, it := range items {
+		key := strings.Join([]string{
+			groupLabel(it, groupBy), it.PlatformKey, it.Version, it.Build, it.DeviceOrNotes, it.Change,
+		}, "\x00")
+		if len(kept) > 0 && key == lastKey {
+			runs[len(runs)-1]++
+			continue
+		}
+		kept = append(kept, it)
+		runs = append(runs, 1)
+		lastKey = key
+	}
+	return kept, runs
+}
+
+// runMark is the count appended to a row standing for n collapsed rows.
+func runMark(n int, ascii bool) string {
+	if ascii {
+		return fmt.Sprintf(" (x%d)", n)
+	}
+	return fmt.Sprintf(" (×%d)", n)
+}
+
+// terminalHeight reads the height from LINES the way terminalWidth reads
+// COLUMNS, assuming 24 rows when it isn't set.
+func terminalHeight() int {
+	if lines := os.Getenv("LINES"); lines != "" {
+		if n, err := strconv.Atoi(lines); err == nil && n > 0 {
+			return n
+		}
+	}
+	return 24
+}
+
+// width is opts.Width, or the terminal width when that is unset.
+func (o renderOptions) width() int {
+	if o.Width > 0 {
+		return o.Width
 	}
-	return string(runes[:width])
+	return terminalWidth()
 }
 
 func terminalWidth() int {
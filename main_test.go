@@ -0,0 +1,1007 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// testNow is the -now of the table tests, the day after the newest fixture
+// item.
+var testNow = time.Date(2023, 11, 8, 0, 0, 0, 0, time.UTC)
+
+// testNormalizeOptions are the normalize options of a run with default
+// flags.
+func testNormalizeOptions(tb testing.TB) normalizeOptions {
+	tb.Helper()
+	keywords, err := parsePreReleaseKeywords(splitList(defaultPreRelease))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return normalizeOptions{
+		FeedFormat:         "auto",
+		ReleasedPhrases:    splitList(defaultReleasedPhrases),
+		NotesPhrase:        defaultNotesPhrase,
+		PreReleaseKeywords: keywords,
+	}
+}
+
+// newItem normalizes a feed entry with the given title and RSS date.
+func newItem(t *testing.T, title, pubDate string) Item {
+	t.Helper()
+	return normalizeItem(rawItem{Title: title, PubDate: pubDate, GUID: title}, testNormalizeOptions(t))
+}
+
+// loadFixture parses and normalizes a feed from testdata.
+func loadFixture(t *testing.T, name string) []Item {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := parseFeed(data, "auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := make([]Item, 0, len(raw))
+	for _, r := range raw {
+		items = append(items, normalizeItem(r, testNormalizeOptions(t)))
+	}
+	return items
+}
+
+// captureStderr returns what fn writes to os.Stderr.
+func captureStderr(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := os.Stderr
+	os.Stderr = w
+	defer func() { os.Stderr = old }()
+	done := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- string(data)
+	}()
+	fn()
+	w.Close()
+	return <-done
+}
+
+// runListArgs runs args as if from the command line, with no
+// config file and an 80-column terminal, and returns what the list or
+// latest command wrote to -output.
+func runListArgs(t *testing.T, args ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("XDG_CACHE_HOME", dir)
+	t.Setenv("COLUMNS", "80")
+	t.Setenv("LANG", "en_US.UTF-8")
+	out := filepath.Join(dir, "out")
+	oldArgs := os.Args
+	os.Args = append(append([]string{"ipsw-timeline"}, args...), "-output", out)
+	t.Cleanup(func() { os.Args = oldArgs })
+
+	runList(parseFlags())
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// plainOptions draws the table as a run with default flags and no color
+// would, at the given width.
+func plainOptions(width int) renderOptions {
+	return renderOptions{
+		Width:            width,
+		Indent:           defaultIndent,
+		Gap:              defaultGap,
+		GroupBy:          "day",
+		Divider:          "dashes",
+		EmptyNotes:       "blank",
+		NotesPlaceholder: "—",
+		NormalizeVersion: "off",
+		Ellipsis:         "…",
+		Now:              testNow,
+	}
+}
+
+// checkGolden compares got with testdata/name.golden; go test -update
+// rewrites the file instead.
+func checkGolden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("output differs from %s:\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+func TestCollapseDuplicates(t *testing.T) {
+	const date = "Tue, 07 Nov 2023 18:00:00 +0000"
+	ios := newItem(t, "iOS 17.1.1 (21B91) for iPhone 15 has been released", date)
+	mac := newItem(t, "macOS 14.1.1 (23B81) has been released", date)
+	items := []Item{ios, ios, ios, mac, ios, mac, mac}
+
+	kept, runs := collapseDuplicates(items, "day")
+	var got []string
+	for i, it := range kept {
+		got = append(got, it.PlatformKey+"×"+strconv.Itoa(runs[i]))
+	}
+	want := "ios×3 macos×1 ios×1 macos×2"
+	if strings.Join(got, " ") != want {
+		t.Errorf("collapseDuplicates = %v, want %s", got, want)
+	}
+
+	other := ios
+	other.Build = "21B92"
+	if kept, _ := collapseDuplicates([]Item{ios, other}, "day"); len(kept) != 2 {
+		t.Errorf("rows with different builds collapsed to %d", len(kept))
+	}
+	nextDay := newItem(t, ios.Title, "Wed, 08 Nov 2023 18:00:00 +0000")
+	if kept, _ := collapseDuplicates([]Item{nextDay, ios}, "day"); len(kept) != 2 {
+		t.Errorf("rows in different groups collapsed to %d", len(kept))
+	}
+}
+
+func TestCollapsedRowFitsWidth(t *testing.T) {
+	ios := newItem(t, "iOS 17.1.1 (21B91) for iPhone 15, iPhone 15 Pro, iPhone 15 Pro Max has been released", "Tue, 07 Nov 2023 18:00:00 +0000")
+	for _, width := range []int{50, 80, 120} {
+		opts := plainOptions(width)
+		opts.CollapseRows = true
+		opts.PadToWidth = true
+		out := renderTableString([]Item{ios, ios, ios}, opts)
+		if !strings.Contains(out, "(×3)") {
+			t.Errorf("width %d: no run mark in\n%s", width, out)
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+			if w := displayWidth(line); w != width {
+				t.Errorf("width %d: line is %d wide: %q", width, w, line)
+			}
+		}
+	}
+}
+
+func TestStripeChar(t *testing.T) {
+	for _, key := range []string{"ios", "macos", ""} {
+		if got := stripeChar(key, false); got != "▌" {
+			t.Errorf("stripeChar(%q, false) = %q, want ▌", key, got)
+		}
+		if got := stripeChar(key, true); got != "|" {
+			t.Errorf("stripeChar(%q, true) = %q, want |", key, got)
+		}
+	}
+
+	opts := plainOptions(40)
+	opts.Divider = "rule"
+	if got := dayDivider("Tue 07 Nov", "31", 40, opts, colorizer{}); !strings.Contains(got, "─") {
+		t.Errorf("unicode rule divider = %q", got)
+	}
+	opts.ASCII = true
+	if got := dayDivider("Tue 07 Nov", "31", 40, opts, colorizer{}); strings.ContainsFunc(got, func(r rune) bool { return r > 127 }) {
+		t.Errorf("ASCII rule divider = %q, want only ASCII", got)
+	}
+}
+
+func TestUnicodeSupported(t *testing.T) {
+	tests := []struct {
+		lcAll, lcCtype, lang, term string
+		want                       bool
+	}{
+		{lang: "en_US.UTF-8", want: true},
+		{lang: "de_DE.utf8", want: true},
+		{lang: "C", want: false},
+		{lang: "en_US.ISO-8859-1", want: false},
+		{lcAll: "C", lang: "en_US.UTF-8", want: false},
+		{lcCtype: "en_US.UTF-8", lang: "C", want: true},
+		{want: true},
+		{term: "xterm-256color", want: true},
+		{term: "dumb", want: false},
+		{lang: "en_US.UTF-8", term: "dumb", want: true},
+	}
+	for _, tt := range tests {
+		t.Setenv("LC_ALL", tt.lcAll)
+		t.Setenv("LC_CTYPE", tt.lcCtype)
+		t.Setenv("LANG", tt.lang)
+		t.Setenv("TERM", tt.term)
+		if got := unicodeSupported(); got != tt.want {
+			t.Errorf("LC_ALL=%q LC_CTYPE=%q LANG=%q TERM=%q: unicodeSupported() = %t, want %t",
+				tt.lcAll, tt.lcCtype, tt.lang, tt.term, got, tt.want)
+		}
+	}
+
+	t.Setenv("LANG", "C")
+	if !tableOptions(Config{}).ASCII {
+		t.Error("non-UTF-8 locale: table options not ASCII")
+	}
+	t.Setenv("LANG", "en_US.UTF-8")
+	if tableOptions(Config{}).ASCII {
+		t.Error("UTF-8 locale: table options ASCII without -ascii-stripe")
+	}
+	if !tableOptions(Config{ASCIIStripe: true}).ASCII {
+		t.Error("-ascii-stripe: table options not ASCII")
+	}
+}
+
+func TestNormalizeVersion(t *testing.T) {
+	tests := []struct {
+		version, mode, want string
+	}{
+		{"17", "minor", "17.0"},
+		{"17.0", "minor", "17.0"},
+		{"17.1 beta 2", "minor", "17.1 beta 2"},
+		{"17 beta", "minor", "17.0 beta"},
+		{"17.0.0", "trim", "17"},
+		{"17.1.0", "trim", "17.1"},
+		{"17.0.1", "trim", "17.0.1"},
+		{"17.0 RC", "trim", "17 RC"},
+		{"17", "off", "17"},
+		{"Sonoma", "minor", "Sonoma"},
+	}
+	for _, tt := range tests {
+		if got := normalizeVersion(tt.version, tt.mode); got != tt.want {
+			t.Errorf("normalizeVersion(%q, %q) = %q, want %q", tt.version, tt.mode, got, tt.want)
+		}
+	}
+}
+
+// platformKeys lists the platform key of each item.
+func platformKeys(items []Item) string {
+	keys := make([]string, len(items))
+	for i, it := range items {
+		keys[i] = it.PlatformKey
+	}
+	return strings.Join(keys, " ")
+}
+
+func TestSortByPlatformOrder(t *testing.T) {
+	items := loadFixture(t, "timeline.rss")
+	sortItems(items, "platform", nil, nil)
+	if got, want := platformKeys(items), "ios ipados macos watchos tvos"; got != want {
+		t.Errorf("default order = %s, want %s", got, want)
+	}
+
+	sortItems(items, "platform", []string{"tvos", "macos"}, nil)
+	if got, want := platformKeys(items), "tvos macos ios ipados watchos"; got != want {
+		t.Errorf("custom order = %s, want %s (unlisted platforms last, by key)", got, want)
+	}
+}
+
+func TestStrictPlatforms(t *testing.T) {
+	const date = "Tue, 07 Nov 2023 18:00:00 +0000"
+	ios := newItem(t, "iOS 17.1.1 (21B91) has been released", date)
+	home := newItem(t, "homeOS 1.0 (1A100) has been released", date)
+	if home.PlatformKey != "other" || home.UnknownPlatform != "homeOS" {
+		t.Fatalf("homeOS item: key %q, unknown platform %q", home.PlatformKey, home.UnknownPlatform)
+	}
+
+	if err := checkPlatforms([]Item{ios}); err != nil {
+		t.Errorf("known platforms only: %v", err)
+	}
+	err := checkPlatforms([]Item{ios, home, home})
+	var platformErr *UnknownPlatformError
+	if !errors.As(err, &platformErr) || !slices.Equal(platformErr.Labels, []string{"homeOS"}) {
+		t.Fatalf("err = %#v, want an UnknownPlatformError for homeOS once", err)
+	}
+	if !strings.Contains(err.Error(), "homeOS") {
+		t.Errorf("message %q does not name the platform", err)
+	}
+	if exitCode(err) != exitUnknownPlatform {
+		t.Errorf("exit code = %d, want %d", exitCode(err), exitUnknownPlatform)
+	}
+}
+
+func TestCombineDeviceAndNotes(t *testing.T) {
+	const notes = "Security fixes"
+	tests := []struct {
+		policy, device string
+		minLen         int
+		want           string
+	}{
+		{"device-only", "Mac", 0, "Mac"},
+		{"device-only", "iPhone 15 Pro", 0, "iPhone 15 Pro"},
+		{"device-only", "Mac", 4, "Mac"},
+		{"notes-only", "Mac", 0, notes},
+		{"notes-only", "iPhone 15 Pro", 0, notes},
+		{"device-then-notes", "Mac", 0, "Mac - " + notes},
+		{"device-then-notes", "iPhone 15 Pro", 0, "iPhone 15 Pro - " + notes},
+		{"device-then-notes", "Mac", 4, notes},
+		{"device-then-notes", "iPhone 15 Pro", 4, "iPhone 15 Pro - " + notes},
+		{"notes-then-device", "Mac", 0, notes + " - Mac"},
+		{"notes-then-device", "iPhone 15 Pro", 0, notes + " - iPhone 15 Pro"},
+		{"notes-then-device", "Mac", 4, notes},
+		{"notes-then-device", "iPhone 15 Pro", 4, notes + " - iPhone 15 Pro"},
+		{"device-then-notes", " - ", 0, notes},
+	}
+	for _, tt := range tests {
+		if got := combineDeviceAndNotes(tt.device, notes, tt.policy, tt.minLen); got != tt.want {
+			t.Errorf("%s, device %q, min %d: got %q, want %q", tt.policy, tt.device, tt.minLen, got, tt.want)
+		}
+	}
+	if got := combineDeviceAndNotes("Mac", "", "device-then-notes", 4); got != "Mac" {
+		t.Errorf("short device without notes = %q, want it kept", got)
+	}
+}
+
+func TestShortDevicesPreserved(t *testing.T) {
+	const desc = "The update has been released with important bug fixes."
+	for _, device := range []string{"Mac", "TV", "HomePod", "iPad mini"} {
+		title := "iOS 17.1.1 (21B91) for " + device + " has been released"
+		it := normalizeItem(rawItem{Title: title, PubDate: "Tue, 07 Nov 2023 18:00:00 +0000", GUID: title, Description: desc}, testNormalizeOptions(t))
+		if it.Build != "21B91" {
+			t.Errorf("device %q: build = %q, want 21B91", device, it.Build)
+		}
+		if want := device + " - with important bug fixes."; it.DeviceOrNotes != want {
+			t.Errorf("device %q: DeviceOrNotes = %q, want %q", device, it.DeviceOrNotes, want)
+		}
+	}
+}
+
+func TestPlatformLimits(t *testing.T) {
+	var items []Item
+	add := func(title string, day int) {
+		items = append(items, newItem(t, title+" has been released", testNow.AddDate(0, 0, -day).Format(time.RFC1123Z)))
+	}
+	add("iOS 17.1.4 (21B94)", 1)
+	add("iOS 17.1.3 (21B93)", 2)
+	add("iOS 17.1.2 (21B92)", 3)
+	add("iOS 17.1.1 (21B91)", 4)
+	add("macOS 14.1.2 (23B92)", 1)
+	add("macOS 14.1.1 (23B81)", 5)
+	add("tvOS 17.1 (21K69)", 2)
+	add("tvOS 17.0 (21J354)", 6)
+	add("watchOS 10.1 (21S71)", 7)
+
+	cfg := Config{Sort: "platform", PlatformLimits: map[string]int{"ios": 3, "macos": 1, "*": 1}}
+	var got []string
+	for _, it := range limitSelection(slices.Clone(items), cfg) {
+		got = append(got, it.Build)
+	}
+	want := "21B94 21B93 21B92 23B92 21S71 21K69"
+	if strings.Join(got, " ") != want {
+		t.Errorf("per-platform caps = %v, want %s", got, want)
+	}
+
+	cfg.Limit = 4
+	if got := limitSelection(slices.Clone(items), cfg); len(got) != 4 || got[3].Build != "23B92" {
+		t.Errorf("caps then -limit 4 = %d items ending %q, want 4 ending 23B92", len(got), got[len(got)-1].Build)
+	}
+
+	cfg = Config{Sort: "platform", PlatformLimits: map[string]int{"ios": 2}}
+	if got := limitSelection(slices.Clone(items), cfg); len(got) != len(items)-2 {
+		t.Errorf("cap on ios only = %d items, want %d: uncapped platforms keep all", len(got), len(items)-2)
+	}
+}
+
+func TestLegendLine(t *testing.T) {
+	resetPlatforms(t)
+	opts := plainOptions(80)
+	opts.ASCII = true
+	want := "  Legend: | iOS  | iPadOS  | macOS  | watchOS  | tvOS  | visionOS  | Other"
+	if got := legendLine(opts); got != want {
+		t.Errorf("legend = %q, want %q", got, want)
+	}
+
+	if err := registerPlatforms([]platformMapping{{Key: "bridgeos", Label: "bridgeOS", Color: "33"}}); err != nil {
+		t.Fatal(err)
+	}
+	opts.Color = true
+	got := legendLine(opts)
+	if !strings.Contains(stripANSI(got), "| visionOS  | bridgeOS  | Other") {
+		t.Errorf("config platform not listed before Other: %q", stripANSI(got))
+	}
+	if !strings.Contains(got, "\033[33m|") || !strings.Contains(got, "\033[31miOS") {
+		t.Errorf("colored legend lacks the platform colors: %q", got)
+	}
+}
+
+func TestMaxTitleLength(t *testing.T) {
+	const title = "iOS 17.1.1 (21B91) for iPhone 15, iPhone 15 Pro has been released"
+	tests := []struct {
+		max                   int
+		version, build, notes string
+	}{
+		{0, "17.1.1", "21B91", "iPhone 15, iPhone 15 Pro"},
+		{80, "17.1.1", "21B91", "iPhone 15, iPhone 15 Pro"},
+		{45, "17.1.1", "21B91", "iPhone 15, iPhone 15…"},
+		{30, "17.1.1", "21B91", "iPhone…"},
+		{17, "17.1.1 (21B9…", "", ""},
+		// The release phrase goes first, so a cut never lands inside it.
+		{50, "17.1.1", "21B91", "iPhone 15, iPhone 15 Pro"},
+	}
+	for _, tt := range tests {
+		opts := testNormalizeOptions(t)
+		opts.MaxTitleLen = tt.max
+		it := normalizeItem(rawItem{Title: title, PubDate: "Tue, 07 Nov 2023 18:00:00 +0000"}, opts)
+		if it.PlatformKey != "ios" || it.Version != tt.version || it.Build != tt.build || it.DeviceOrNotes != tt.notes {
+			t.Errorf("max %d: %s %q (%q) %q, want ios %q (%q) %q",
+				tt.max, it.PlatformKey, it.Version, it.Build, it.DeviceOrNotes, tt.version, tt.build, tt.notes)
+		}
+	}
+
+	// Wide characters count two columns, so the cut title fits max columns.
+	opts := testNormalizeOptions(t)
+	opts.MaxTitleLen = 30
+	it := normalizeItem(rawItem{Title: "iOS 17.1 (21B74) for 日本語のデバイス has been released", PubDate: "Tue, 07 Nov 2023 18:00:00 +0000"}, opts)
+	if it.Version != "17.1" || it.Build != "21B74" || it.DeviceOrNotes != "日本語の…" {
+		t.Errorf("wide title: %q (%q) %q, want 17.1 (21B74) %q", it.Version, it.Build, it.DeviceOrNotes, "日本語の…")
+	}
+	for _, tt := range []struct {
+		max  int
+		want string
+	}{
+		{7, "日本語…"},
+		{8, "日本語…"},
+		{16, "日本語のデバイス"},
+	} {
+		if got := limitTitle("日本語のデバイス", tt.max); got != tt.want || displayWidth(got) > tt.max {
+			t.Errorf("limitTitle(%d) = %q, want %q", tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestTruncateEllipsisWidth(t *testing.T) {
+	tests := []struct {
+		mark, s string
+		width   int
+		want    string
+	}{
+		{"…", "iPhone 15 Pro", 8, "iPhone …"},
+		{"…", "iPhone", 6, "iPhone"},
+		{"...", "iPhone 15 Pro", 8, "iPhon..."},
+		{"...", "iPhone 15 Pro", 3, "iPh"},
+		{"", "iPhone 15 Pro", 8, "iPhone 1"},
+		{"…", "Gerät für iPhone", 7, "Gerät …"},
+		{"…", "日本語のデバイス", 7, "日本語…"},
+	}
+	for _, tt := range tests {
+		got := truncate(tt.s, tt.width, tt.mark)
+		if got != tt.want {
+			t.Errorf("ellipsis %q: truncate(%q, %d) = %q, want %q", tt.mark, tt.s, tt.width, got, tt.want)
+		}
+		if displayWidth(got) > tt.width {
+			t.Errorf("ellipsis %q: truncate(%q, %d) is %d wide", tt.mark, tt.s, tt.width, displayWidth(got))
+		}
+	}
+
+	// The table takes the mark from its options alone.
+	items := loadFixture(t, "timeline.rss")
+	for _, mark := range []string{"...", ""} {
+		opts := plainOptions(60)
+		opts.Ellipsis = mark
+		out := renderTableString(items, opts)
+		if strings.Contains(out, "…") || mark != "" && !strings.Contains(out, "iPhone 15, iPh...") {
+			t.Errorf("Ellipsis %q: table\n%s", mark, out)
+		}
+	}
+}
+
+func TestTruncateAndPad(t *testing.T) {
+	tests := []struct {
+		s         string
+		width     int
+		truncated string
+		padded    string
+	}{
+		{"iOS", 6, "iOS", "iOS   "},
+		{"iPadOS", 6, "iPadOS", "iPadOS"},
+		{"watchOS", 6, "watch…", "watchOS"},
+		{"Gerät", 6, "Gerät", "Gerät "},
+		{"Gerät", 5, "Gerät", "Gerät"},
+		{"Gerätefamilie", 5, "Gerä…", "Gerätefamilie"},
+		{"", 3, "", "   "},
+		{"iOS", 0, "", "iOS"},
+		{"iOS", 1, "i", "iOS"},
+	}
+	for _, tt := range tests {
+		if got := truncate(tt.s, tt.width, "…"); got != tt.truncated {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.s, tt.width, got, tt.truncated)
+		}
+		if got := pad(tt.s, tt.width); got != tt.padded {
+			t.Errorf("pad(%q, %d) = %q, want %q", tt.s, tt.width, got, tt.padded)
+		}
+		if cell := pad(truncate(tt.s, tt.width, "…"), tt.width); displayWidth(cell) != tt.width {
+			t.Errorf("pad(truncate(%q, %d)) = %q, %d wide", tt.s, tt.width, cell, displayWidth(cell))
+		}
+	}
+}
+
+func TestPlatformCodeForKey(t *testing.T) {
+	resetPlatforms(t)
+	if err := registerPlatforms([]platformMapping{{Key: "bridgeos", Label: "bridgeOS"}, {Key: "hp", Label: "HP"}}); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{
+		"ios":      "iOS",
+		"ipados":   "iPd",
+		"macos":    "mac",
+		"watchos":  "wch",
+		"tvos":     "tv ",
+		"visionos": "vis",
+		"other":    "oth",
+		"bridgeos": "bri",
+		"hp":       "HP ",
+	} {
+		if got := platformCodeForKey(key); got != want {
+			t.Errorf("platformCodeForKey(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestCustomReleasedPhrases(t *testing.T) {
+	opts := testNormalizeOptions(t)
+	opts.ReleasedPhrases = splitList("ist erschienen,erschienen")
+	opts.NotesPhrase = "ist erschienen"
+	for _, title := range []string{
+		"iOS 17.1.1 (21B91) ist erschienen",
+		"iOS 17.1.1 (21B91) ist erschienen.",
+		"iOS 17.1.1 (21B91) Erschienen",
+	} {
+		it := normalizeItem(rawItem{
+			Title:       title,
+			PubDate:     "Tue, 07 Nov 2023 18:00:00 +0000",
+			Description: "iOS 17.1.1 ist erschienen und behebt Fehler beim Laden.",
+		}, opts)
+		if it.Version != "17.1.1" || it.Build != "21B91" {
+			t.Errorf("%q: version %q, build %q, want 17.1.1 and 21B91", title, it.Version, it.Build)
+		}
+		if it.Notes != "und behebt Fehler beim Laden." {
+			t.Errorf("%q: notes %q", title, it.Notes)
+		}
+	}
+
+	// The English defaults no longer apply once the list is replaced.
+	it := normalizeItem(rawItem{Title: "iOS 17.1.1 (21B91) has been released"}, opts)
+	if it.Build != "" {
+		t.Errorf("English suffix stripped with a German phrase list: build %q", it.Build)
+	}
+}
+
+func TestPinnedPlatformsLead(t *testing.T) {
+	items := loadFixture(t, "timeline.rss")
+	sortItems(items, "date", nil, []string{"tvos", "watchos"})
+	if got, want := platformKeys(items), "tvos watchos ios macos ipados"; got != want {
+		t.Errorf("date sort, pin tvos,watchos = %s, want %s", got, want)
+	}
+
+	sortItems(items, "platform", nil, []string{"macos"})
+	if got, want := platformKeys(items), "macos ios ipados watchos tvos"; got != want {
+		t.Errorf("platform sort, pin macos = %s, want %s", got, want)
+	}
+
+	var pinned []Item
+	for _, title := range []string{"iOS 17.1 (21B74)", "iOS 17.1.1 (21B91)", "macOS 14.1.1 (23B81)"} {
+		pinned = append(pinned, newItem(t, title+" has been released", "Tue, 07 Nov 2023 18:00:00 +0000"))
+	}
+	pinned[0].PubDate = pinned[0].PubDate.AddDate(0, 0, -7)
+	sortItems(pinned, "date", nil, []string{"ios"})
+	if pinned[0].Build != "21B91" || pinned[1].Build != "21B74" {
+		t.Errorf("pinned items lost their date order: %s, %s", pinned[0].Build, pinned[1].Build)
+	}
+}
+
+func TestLoadDirMergesFixtures(t *testing.T) {
+	silence(t)
+	dir := t.TempDir()
+	older := `<?xml version="1.0"?><rss version="2.0"><channel><title>Archive</title>
+<item><title>iOS 17.1.1 (21B91) for iPhone 15 has been released</title><guid>ios-21B91</guid><pubDate>Tue, 07 Nov 2023 18:00:00 +0000</pubDate></item>
+<item><title>iOS 17.0.3 (21A360) has been released</title><guid>ios-21A360</guid><pubDate>Wed, 04 Oct 2023 17:00:00 +0000</pubDate></item>
+</channel></rss>`
+	for name, data := range map[string][]byte{
+		"a.rss":     readFixture(t, "timeline.rss"),
+		"b.xml":     []byte(older),
+		"notes.txt": []byte("not a feed"),
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := testConfig(t.TempDir())
+	cfg.Feeds = []string{"file://" + dir}
+	cfg.FeedFormat = "auto"
+	cfg.DedupeBy = "guid"
+	items, err := loadFeeds(newFetcher(cfg), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var guids []string
+	for _, it := range items {
+		guids = append(guids, it.GUID)
+	}
+	want := "ios-21B91 macos-23C5041e watchos-21S71 ipados-21B74 tvos-21K69 ios-21A360"
+	if strings.Join(guids, " ") != want {
+		t.Errorf("merged GUIDs = %v, want %s", guids, want)
+	}
+}
+
+func TestDayDividerMultibyteLabel(t *testing.T) {
+	const day = "Dienstag, 7. März 2023 · KW 45"
+	opts := plainOptions(60)
+	for _, style := range []string{"dashes", "rule"} {
+		opts.Divider = style
+		got := dayDivider(day, "31", 60, opts, colorizer{})
+		if w := displayWidth(got); w != 60 {
+			t.Errorf("%s: divider is %d wide, want 60: %q", style, w, got)
+		}
+		if !strings.Contains(got, " "+day+" ") {
+			t.Errorf("%s: divider %q lost the label", style, got)
+		}
+	}
+
+	opts.Divider = "dashes"
+	if got, want := dayDivider(day, "31", 40, opts, colorizer{}), " "+day+" --------"; got != want {
+		t.Errorf("dashes at 40 = %q, want %q", got, want)
+	}
+	if got := dayDivider(day, "31", 20, opts, colorizer{}); displayWidth(got) != 20 || !strings.HasSuffix(got, "…") {
+		t.Errorf("label wider than the table = %q, want it cut to 20", got)
+	}
+}
+
+func TestPreReleaseKeywords(t *testing.T) {
+	const date = "Tue, 07 Nov 2023 18:00:00 +0000"
+	tests := []struct {
+		title, stage, keyword string
+	}{
+		{"iOS 17.2 beta 3 (21C5046c) has been released", "beta", "beta"},
+		{"iOS 17.2 Public Beta (21C5046c) has been released", "beta", "public beta"},
+		{"macOS 14.2 RC (23C63) has been released", "rc", "rc"},
+		{"macOS 14.2 Release Candidate (23C63) has been released", "rc", "release candidate"},
+		{"iOS 13.0 GM (17A577) has been released", "rc", "gm"},
+		{"iOS 17.1.1 (21B91) has been released", "", ""},
+		{"watchOS 10.1 (21S71) for Apple Watch Series 9 has been released", "", ""},
+		{"iOS 17.1 (21B74) for iPhone XR has been released", "", ""},
+	}
+	for _, tt := range tests {
+		it := newItem(t, tt.title, date)
+		if it.PreReleaseStage != tt.stage || it.PreReleaseKeyword != tt.keyword || it.PreRelease != (tt.stage != "") {
+			t.Errorf("%q: stage %q keyword %q pre-release %t, want %q %q",
+				tt.title, it.PreReleaseStage, it.PreReleaseKeyword, it.PreRelease, tt.stage, tt.keyword)
+		}
+	}
+
+	custom, err := parsePreReleaseKeywords(splitList("beta,preview,seed=rc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := testNormalizeOptions(t)
+	opts.PreReleaseKeywords = custom
+	for title, stage := range map[string]string{
+		"visionOS 1.0 Preview (21N5165g) has been released": "beta",
+		"macOS 14.2 Seed 4 (23C5055b) has been released":    "rc",
+		"iOS 13.0 GM (17A577) has been released":            "",
+	} {
+		if got := normalizeItem(rawItem{Title: title, PubDate: date}, opts).PreReleaseStage; got != stage {
+			t.Errorf("custom keywords, %q: stage %q, want %q", title, got, stage)
+		}
+	}
+
+	for _, bad := range []string{"beta=alpha", "=rc"} {
+		if _, err := parsePreReleaseKeywords([]string{bad}); err == nil {
+			t.Errorf("parsePreReleaseKeywords(%q): no error", bad)
+		}
+	}
+}
+
+func TestDimOldBands(t *testing.T) {
+	at := func(age time.Duration) Item {
+		return newItem(t, "iOS 17.1.1 (21B91) has been released", testNow.Add(-age).Format(time.RFC1123Z))
+	}
+	day := 24 * time.Hour
+	tests := []struct {
+		age    time.Duration
+		band   int
+		prefix string
+	}{
+		{time.Hour, 0, "\033[31m"},
+		{7 * day, 0, "\033[31m"},
+		{7*day + time.Minute, 1, "\033[2;31m"},
+		{30 * day, 1, "\033[2;31m"},
+		{30*day + time.Minute, 2, "\033[2m"},
+	}
+	for _, tt := range tests {
+		it := at(tt.age)
+		if got := ageBand(it, testNow); got != tt.band {
+			t.Errorf("age %v: band %d, want %d", tt.age, got, tt.band)
+		}
+
+		opts := plainOptions(80)
+		opts.Color = true
+		opts.DimOld = true
+		opts.Fields = []string{"platform"}
+		row := strings.Split(renderTableString([]Item{it}, opts), "\n")[3]
+		if stripe := strings.TrimLeft(row, " "); !strings.HasPrefix(stripe, tt.prefix+"▌") {
+			t.Errorf("age %v: row %q, want the stripe in %q", tt.age, row, tt.prefix)
+		}
+	}
+
+	expected := at(90 * day)
+	expected.Provenance = provenanceExpected
+	if got := ageBand(expected, testNow); got != 0 {
+		t.Errorf("expected item: band %d, want 0", got)
+	}
+}
+
+func TestInvalidUTF8Title(t *testing.T) {
+	raw := rawItem{
+		Title:       "iOS 17.1.1 (21B91) for iPhone\xff 15 Pro\xc3 has been released",
+		PubDate:     "Tue, 07 Nov 2023 18:00:00 +0000",
+		GUID:        "ios-\xfe21B91",
+		Description: "iOS 17.1.1 has been released with \xe2\x80 fixes.",
+	}
+	it := normalizeItem(raw, testNormalizeOptions(t))
+	if it.Build != "21B91" || it.RawDevice != "iPhone� 15 Pro�" {
+		t.Errorf("build %q, device %q", it.Build, it.RawDevice)
+	}
+
+	for width := 20; width <= 80; width += 3 {
+		out := renderTableString([]Item{it}, plainOptions(width))
+		if !utf8.ValidString(out) {
+			t.Fatalf("width %d: table isn't valid UTF-8:\n%q", width, out)
+		}
+	}
+	var b strings.Builder
+	if err := renderJSON([]Item{it}, &b); err != nil {
+		t.Fatal(err)
+	}
+	if !utf8.ValidString(b.String()) || !strings.Contains(b.String(), `"guid": "ios-�21B91"`) {
+		t.Errorf("JSON isn't valid UTF-8 with U+FFFD for the bad bytes:\n%s", b.String())
+	}
+}
+
+func TestStaleAfterWarning(t *testing.T) {
+	feed := "file://" + filepath.Join("testdata", "timeline.rss")
+	built := time.Date(2023, 11, 8, 18, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		now  time.Time
+		warn bool
+	}{
+		{built.Add(71 * time.Hour), false},
+		{built.Add(73 * time.Hour), true},
+		{built.Add(30 * 24 * time.Hour), true},
+	} {
+		cfg := Config{StaleAfter: 72 * time.Hour, Now: tt.now, MaxPages: defaultMaxPages}
+		stderr := captureStderr(t, func() {
+			if _, err := loadItems(newFetcher(cfg), feed, testNormalizeOptions(t)); err != nil {
+				t.Fatal(err)
+			}
+		})
+		if got := strings.Contains(stderr, "may have stopped updating"); got != tt.warn {
+			t.Errorf("now %v: warned %t, want %t: %q", tt.now, got, tt.warn, stderr)
+		}
+	}
+
+	silence(t)
+	cfg := Config{StaleAfter: time.Hour, Now: built.Add(48 * time.Hour), MaxPages: defaultMaxPages}
+	if stderr := captureStderr(t, func() { loadItems(newFetcher(cfg), feed, testNormalizeOptions(t)) }); stderr != "" {
+		t.Errorf("-quiet still warned: %q", stderr)
+	}
+}
+
+func TestFixedNow(t *testing.T) {
+	args := []string{
+		"-f", "file://" + filepath.Join("testdata", "timeline.rss"),
+		"-now", "2023-11-08T12:00:00Z",
+		"-highlight-age", "1d",
+		"-max-age", "14d",
+	}
+	first := runListArgs(t, args...)
+	if second := runListArgs(t, args...); second != first {
+		t.Errorf("two runs with the same -now differ:\n%s\n%s", first, second)
+	}
+	checkGolden(t, "fixed-now", first)
+}
+
+func TestLatestJSONByPlatform(t *testing.T) {
+	older := filepath.Join(t.TempDir(), "older.rss")
+	err := os.WriteFile(older, []byte(`<rss version="2.0"><channel>
+<item><title>iOS 17.1 (21B74) has been released</title><guid>ios-21B74</guid><pubDate>Wed, 25 Oct 2023 17:00:00 +0000</pubDate></item>
+<item><title>macOS 14.1 (23B74) has been released</title><guid>macos-23B74</guid><pubDate>Wed, 25 Oct 2023 17:00:00 +0000</pubDate></item>
+</channel></rss>`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := runListArgs(t, "latest", "-format", "json",
+		"-f", "file://"+filepath.Join("testdata", "timeline.rss"), "-f", "file://"+older)
+
+	var byPlatform map[string]jsonItem
+	if err := json.Unmarshal([]byte(out), &byPlatform); err != nil {
+		t.Fatalf("not a keyed object: %v\n%s", err, out)
+	}
+	builds := make(map[string]string)
+	for key, it := range byPlatform {
+		builds[key] = it.Build
+		if it.PlatformKey != key {
+			t.Errorf("%s holds a %s item", key, it.PlatformKey)
+		}
+	}
+	want := map[string]string{"ios": "21B91", "macos": "23C5041e", "watchos": "21S71", "ipados": "21B74", "tvos": "21K69"}
+	if !maps.Equal(builds, want) {
+		t.Errorf("latest builds = %v, want %v", builds, want)
+	}
+}
+
+func TestFilterMaxAge(t *testing.T) {
+	for _, tt := range []struct {
+		flag string
+		age  time.Duration
+	}{{"30d", 30 * 24 * time.Hour}, {"2w", 14 * 24 * time.Hour}, {"1w3d", 10 * 24 * time.Hour}} {
+		maxAge, err := parseHumanDuration(tt.flag)
+		if err != nil || maxAge != tt.age {
+			t.Fatalf("-max-age %s = %v, %v; want %v", tt.flag, maxAge, err, tt.age)
+		}
+		at := func(age time.Duration) Item {
+			return newItem(t, "iOS 17.1.1 (21B91) has been released", testNow.Add(-age).Format(time.RFC1123Z))
+		}
+		items := []Item{at(maxAge - time.Second), at(maxAge), at(maxAge + time.Second), newItem(t, "iOS 17.1 (21B74) has been released", "someday")}
+		got := filterMaxAge(items, maxAge, testNow)
+		if len(got) != 2 || !got[1].PubDate.Equal(testNow.Add(-maxAge)) {
+			t.Errorf("-max-age %s kept %d items, want the two within it, the boundary included", tt.flag, len(got))
+		}
+	}
+	if got := filterMaxAge(loadFixture(t, "timeline.rss"), 0, testNow); len(got) != 5 {
+		t.Errorf("-max-age 0 kept %d items, want all 5", len(got))
+	}
+}
+
+func TestFoldPreReleases(t *testing.T) {
+	const date = "Tue, 07 Nov 2023 18:00:00 +0000"
+	items := []Item{
+		newItem(t, "iOS 17.1 (21B74) has been released", date),
+		newItem(t, "iOS 17.1 RC (21B71) has been released", date),
+		newItem(t, "iOS 17.1 beta 3 (21B5066a) has been released", date),
+		newItem(t, "iOS 17.1.1 beta 1 (21B5091a) has been released", date),
+		newItem(t, "macOS 14.2 beta 2 (23C5041e) has been released", date),
+		newItem(t, "macOS 14.2 beta 1 (23C5030f) has been released", date),
+	}
+	var got []string
+	for _, it := range foldPreReleases(items) {
+		got = append(got, it.Title)
+	}
+	want := []string{
+		"iOS 17.1 (21B74) has been released",
+		"iOS 17.1.1 beta 1 (21B5091a) has been released",
+		"macOS 14.2 beta 2 (23C5041e) has been released",
+		"macOS 14.2 beta 1 (23C5030f) has been released",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("foldPreReleases kept %q, want %q", got, want)
+	}
+}
+
+func TestFilterItemsCase(t *testing.T) {
+	items := loadFixture(t, "timeline.rss")
+	keys := func(items []Item) []string {
+		var out []string
+		for _, it := range items {
+			out = append(out, it.PlatformKey)
+		}
+		return out
+	}
+	tests := []struct {
+		contains, exclude []string
+		caseSensitive     bool
+		want              []string
+	}{
+		{[]string{"IPHONE"}, nil, false, []string{"ios"}},
+		{[]string{"IPHONE"}, nil, true, nil},
+		{[]string{"iPhone"}, nil, true, []string{"ios"}},
+		{[]string{"os 17.1", "for"}, nil, false, []string{"ios", "ipados", "tvos"}},
+		{[]string{"os 17.1", "for"}, []string{"APPLE TV"}, false, []string{"ios", "ipados"}},
+		{[]string{"OS 17.1", "for"}, []string{"APPLE TV"}, true, []string{"ios", "ipados", "tvos"}},
+		{[]string{"", " "}, []string{""}, false, []string{"ios", "macos", "watchos", "ipados", "tvos"}},
+	}
+	for _, tt := range tests {
+		got := keys(filterItems(items, tt.contains, tt.exclude, tt.caseSensitive))
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("contains %q exclude %q case-sensitive %t: kept %q, want %q",
+				tt.contains, tt.exclude, tt.caseSensitive, got, tt.want)
+		}
+	}
+}
+
+func TestHasSecurityContent(t *testing.T) {
+	for text, want := range map[string]bool{
+		"watchOS 10.1 has been released with security fixes for CVE-2023-42846.": true,
+		"Fixes CVE-2023-41990 and CVE-2023-42824":                                true,
+		"cve-2023-42846 in lower case":                                           true,
+		"Security Response 17.4.1 (c)":                                           true,
+		"iOS 17.1.1 has been released with a fix for a wireless charging issue.": false,
+		"CVE-pending":           false,
+		"securityd crash fixed": false,
+		"":                      false,
+	} {
+		if got := hasSecurityContent(text); got != want {
+			t.Errorf("hasSecurityContent(%q) = %t, want %t", text, got, want)
+		}
+	}
+
+	var flagged []string
+	for _, it := range loadFixture(t, "timeline.rss") {
+		if it.SecurityContent {
+			flagged = append(flagged, it.PlatformKey)
+		}
+	}
+	if !slices.Equal(flagged, []string{"watchos"}) {
+		t.Errorf("fixture items with security content: %q, want only watchOS", flagged)
+	}
+}
+
+func TestFilterNotes(t *testing.T) {
+	items := loadFixture(t, "timeline.rss")
+	punct := newItem(t, "visionOS 1.0.1 (21N311) has been released", "Tue, 07 Nov 2023 18:00:00 +0000")
+	punct.Notes = " ."
+	items = append(items, punct)
+	keys := func(items []Item) []string {
+		var out []string
+		for _, it := range items {
+			out = append(out, it.PlatformKey)
+		}
+		return out
+	}
+	if got, want := keys(filterNotes(items, true)), []string{"ios", "watchos"}; !slices.Equal(got, want) {
+		t.Errorf("-has-notes kept %q, want %q", got, want)
+	}
+	// Devices alone, as on iPadOS and tvOS, are not notes.
+	if got, want := keys(filterNotes(items, false)), []string{"macos", "ipados", "tvos", "visionos"}; !slices.Equal(got, want) {
+		t.Errorf("-has-notes=false kept %q, want %q", got, want)
+	}
+}
+
+func TestPlatformColors(t *testing.T) {
+	resetPlatforms(t)
+	seen := map[string]string{}
+	for _, key := range []string{"ios", "ipados", "macos", "watchos", "tvos", "visionos", "other"} {
+		code := platformColor(key)
+		if prev, ok := seen[code]; ok {
+			t.Errorf("%s and %s share color %s", prev, key, code)
+		}
+		seen[code] = key
+	}
+
+	// Platforms without a color of their own hash to a bright one, the
+	// same on every call. These four happen to land on different ones.
+	for _, key := range []string{"bridgeos", "airpods", "ipodos", "displayos"} {
+		code := platformColor(key)
+		if !slices.Contains(hashedColors, code) {
+			t.Errorf("%s: color %s, want one of %q", key, code, hashedColors)
+		}
+		if again := platformColor(key); again != code {
+			t.Errorf("%s: color %s then %s, want it stable", key, code, again)
+		}
+		if prev, ok := seen[code]; ok {
+			t.Errorf("%s and %s share color %s", prev, key, code)
+		}
+		seen[code] = key
+	}
+
+	customPlatforms = map[string]customPlatform{"bridgeos": {color: "38;5;208"}}
+	if got := platformColor("bridgeos"); got != "38;5;208" {
+		t.Errorf("configured bridgeOS color: %s, want 38;5;208", got)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"slices"
+	"strings"
+)
+
+// mergeExpected tags expected items and merges them with released ones.
+// When an expected item names the same platform and version as a released
+// item, the release has shipped and the expected entry is dropped: the
+// released item always wins.
+func mergeExpected(released, expected []Item) []Item {
+	shipped := make(map[string]bool, len(released))
+	for _, it := range released {
+		if key := releaseKey(it); key != "" {
+			shipped[key] = true
+		}
+	}
+
+	merged := append([]Item(nil), released...)
+	for _, it := range expected {
+		if shipped[releaseKey(it)] {
+			continue
+		}
+		it.Provenance = provenanceExpected
+		merged = append(merged, it)
+	}
+	return merged
+}
+
+// releaseKey identifies a release independently of the device it targets.
+// Items without a version have no key.
+func releaseKey(it Item) string {
+	version := strings.ToLower(normalizeSpace(it.Version))
+	if version == "" {
+		return ""
+	}
+	return it.PlatformKey + "\x00" + version
+}
+
+// dedupeKeys are the -deduplicate-by policies: what makes two items the
+// same release.
+var dedupeKeys = map[string]func(Item) string{
+	"guid":                   itemID,
+	"link":                   linkID,
+	"platform+version+build": buildID,
+}
+
+// linkID identifies an item by its link, falling back to itemID for items
+// without one.
+func linkID(it Item) string {
+	if link := strings.TrimSpace(it.Link); link != "" {
+		return link
+	}
+	return itemID(it)
+}
+
+// buildID identifies a build regardless of the device it was posted for.
+// Items without a version fall back to itemID rather than all collapsing
+// into one.
+func buildID(it Item) string {
+	key := releaseKey(it)
+	if key == "" {
+		return itemID(it)
+	}
+	return key + "\x00" + strings.ToLower(strings.TrimSpace(it.Build))
+}
+
+// dedupeItems drops items that share a key under policy by (see
+// dedupeKeys), keeping the copy from the most preferred source. Sources
+// named in priority rank first, in that order; ties, including sources not
+// listed, go to the copy loaded first. Under platform+version+build the
+// kept copy also lists the devices of the copies it replaced. With
+// warnConflicts, items sharing a GUID but not their content are reported,
+// since the feed may have reused a GUID for a different release.
+func dedupeItems(items []Item, priority []string, by string, warnConflicts bool) []Item {
+	key, ok := dedupeKeys[by]
+	if !ok {
+		key = itemID
+	}
+	rank := func(source string) int {
+		for i, p := range priority {
+			if p == source {
+				return i
+			}
+		}
+		return len(priority)
+	}
+
+	index := make(map[string]int, len(items))
+	out := make([]Item, 0, len(items))
+	for _, it := range items {
+		id := key(it)
+		if i, ok := index[id]; ok {
+			kept, dropped := out[i], it
+			if rank(it.Source) < rank(out[i].Source) {
+				kept, dropped = it, out[i]
+			}
+			if by == "platform+version+build" {
+				kept = mergeDevices(kept, dropped)
+			}
+			if warnConflicts && by == "guid" && strings.TrimSpace(it.GUID) != "" && !sameContent(kept, dropped) {
+				warnf("duplicate guid %q for different items: kept %q, dropped %q", id, kept.Title, dropped.Title)
+			}
+			out[i] = kept
+			continue
+		}
+		index[id] = len(out)
+		out = append(out, it)
+	}
+	return out
+}
+
+// sameContent reports whether a and b carry the same release, whatever
+// their source.
+func sameContent(a, b Item) bool {
+	return a.Title == b.Title && a.Link == b.Link && a.PubDate.Equal(b.PubDate) && a.Description == b.Description
+}
+
+// mergeDevices adds the devices of other that kept doesn't list yet, and
+// rebuilds the device column from the combined list.
+func mergeDevices(kept, other Item) Item {
+	devices := append([]string{}, kept.Devices...)
+	for _, d := range other.Devices {
+		if !slices.Contains(devices, d) {
+			devices = append(devices, d)
+		}
+	}
+	if len(devices) == len(kept.Devices) {
+		return kept
+	}
+	kept.Devices = devices
+	kept.RawDevice = strings.Join(devices, ", ")
+	kept.DeviceOrNotes = combineDeviceAndNotes(kept.RawDevice, kept.Notes, defaultNotesPolicy, defaultMinDeviceLen)
+	return kept
+}
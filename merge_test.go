@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDedupeBy(t *testing.T) {
+	const date = "Wed, 25 Oct 2023 17:00:00 +0000"
+	item := func(title, guid, link string) Item {
+		it := newItem(t, title, date)
+		it.GUID, it.Link = guid, link
+		return it
+	}
+	items := []Item{
+		item("iOS 17.1 (21B74) for iPhone 15 has been released", "g1", "https://ipsw.me/a"),
+		item("iOS 17.1 (21B74) for iPhone 14 has been released", "g2", "https://ipsw.me/a"),
+		item("iOS 17.1 (21B75) for iPhone 15 has been released", "g1", "https://ipsw.me/c"),
+	}
+
+	tests := []struct {
+		by   string
+		want string
+	}{
+		{"guid", "g1 21B74 iPhone 15 | g2 21B74 iPhone 14"},
+		{"link", "g1 21B74 iPhone 15 | g1 21B75 iPhone 15"},
+		{"platform+version+build", "g1 21B74 iPhone 15, iPhone 14 | g1 21B75 iPhone 15"},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, it := range dedupeItems(append([]Item(nil), items...), nil, tt.by, false) {
+			got = append(got, it.GUID+" "+it.Build+" "+it.RawDevice)
+		}
+		if strings.Join(got, " | ") != tt.want {
+			t.Errorf("-deduplicate-by %s = %q, want %q", tt.by, strings.Join(got, " | "), tt.want)
+		}
+	}
+}
+
+func TestDedupePrefersSource(t *testing.T) {
+	a := newItem(t, "iOS 17.1 (21B74) has been released", "Wed, 25 Oct 2023 17:00:00 +0000")
+	b := a
+	a.Source, b.Source = "mirror", "primary"
+	got := dedupeItems([]Item{a, b}, []string{"primary"}, "guid", false)
+	if len(got) != 1 || got[0].Source != "primary" {
+		t.Errorf("kept %+v, want the primary copy", got)
+	}
+	got = dedupeItems([]Item{a, b}, nil, "guid", false)
+	if len(got) != 1 || got[0].Source != "mirror" {
+		t.Errorf("no priority: kept %+v, want the first copy", got)
+	}
+}
+
+func TestWarnDuplicateGUIDs(t *testing.T) {
+	const date = "Wed, 25 Oct 2023 17:00:00 +0000"
+	first := newItem(t, "iOS 17.1 (21B74) has been released", date)
+	first.GUID = "ios-17.1"
+	mirrored := first
+	mirrored.Source = "mirror"
+	reused := newItem(t, "iOS 17.1.1 (21B91) has been released", date)
+	reused.GUID = first.GUID
+
+	tests := []struct {
+		name  string
+		items []Item
+		warn  bool
+		want  string
+	}{
+		{"different content", []Item{first, reused}, true, `duplicate guid "ios-17.1" for different items: kept "iOS 17.1 (21B74) has been released", dropped "iOS 17.1.1 (21B91) has been released"`},
+		{"same content from another source", []Item{first, mirrored}, true, ""},
+		{"warning off", []Item{first, reused}, false, ""},
+	}
+	for _, tt := range tests {
+		var got []Item
+		log := captureStderr(t, func() { got = dedupeItems(tt.items, nil, "guid", tt.warn) })
+		if len(got) != 1 || got[0].Build != "21B74" {
+			t.Errorf("%s: kept %d items, want the first copy alone", tt.name, len(got))
+		}
+		if tt.want == "" && log != "" || tt.want != "" && !strings.Contains(log, tt.want) {
+			t.Errorf("%s: stderr %q, want %q", tt.name, log, tt.want)
+		}
+	}
+}
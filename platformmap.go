@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// parsePlatformMap reads a flag of comma-separated entries, each either
+// bare ("21A", stored under "*" for every platform) or for one platform
+// ("macos=23B"). parse reads the value of an entry; usage describes the
+// entry syntax for the error about one it rejects.
+func parsePlatformMap[T any](flagName string, entries []string, usage string, parse func(string) (T, bool)) (map[string]T, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	values := make(map[string]T, len(entries))
+	for _, e := range entries {
+		key, value := "*", e
+		if k, v, ok := strings.Cut(e, "="); ok {
+			key, value = strings.ToLower(strings.TrimSpace(k)), v
+		}
+		parsed, ok := parse(value)
+		if key == "" || !ok {
+			return nil, fmt.Errorf("invalid %s entry %q: use %s", flagName, e, usage)
+		}
+		values[key] = parsed
+	}
+	return values, nil
+}
+
+// platformValue is the entry of values for key, or the "*" entry when the
+// platform has none of its own.
+func platformValue[T any](values map[string]T, key string) (T, bool) {
+	if v, ok := values[key]; ok {
+		return v, true
+	}
+	v, ok := values["*"]
+	return v, ok
+}
+
+// filterPerPlatform keeps the items that keep accepts given the entry of
+// values for their platform. Platforms without an entry are kept as they
+// are.
+func filterPerPlatform[T any](items []Item, values map[string]T, keep func(Item, T) bool) []Item {
+	if len(values) == 0 {
+		return items
+	}
+	var out []Item
+	for _, it := range items {
+		if v, ok := platformValue(values, it.PlatformKey); !ok || keep(it, v) {
+			out = append(out, it)
+		}
+	}
+	return out
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// titleRewrite is one -title-replace or -title-regex rule. Literal rules
+// have a nil pattern.
+type titleRewrite struct {
+	old     string
+	new     string
+	pattern *regexp.Regexp
+}
+
+// parseTitleRewrites reads "old=new" entries, literal ones first and then
+// regular expressions, splitting each at its first "=". A regex
+// replacement may use $1-style references to its groups.
+func parseTitleRewrites(literal, regex []string) ([]titleRewrite, error) {
+	var rules []titleRewrite
+	for _, e := range literal {
+		old, repl, ok := strings.Cut(e, "=")
+		if !ok || old == "" {
+			return nil, fmt.Errorf("invalid title-replace %q: use old=new", e)
+		}
+		rules = append(rules, titleRewrite{old: old, new: repl})
+	}
+	for _, e := range regex {
+		expr, repl, ok := strings.Cut(e, "=")
+		if !ok || expr == "" {
+			return nil, fmt.Errorf("invalid title-regex %q: use pattern=replacement", e)
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid title-regex %q: %v", e, err)
+		}
+		rules = append(rules, titleRewrite{old: expr, new: repl, pattern: re})
+	}
+	return rules, nil
+}
+
+// rewriteTitle applies rules to title in order, each to the output of the
+// one before.
+func rewriteTitle(title string, rules []titleRewrite) string {
+	for _, r := range rules {
+		if r.pattern != nil {
+			title = r.pattern.ReplaceAllString(title, r.new)
+		} else {
+			title = strings.ReplaceAll(title, r.old, r.new)
+		}
+	}
+	return title
+}
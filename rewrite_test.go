@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+func TestRewriteTitle(t *testing.T) {
+	rules, err := parseTitleRewrites(
+		[]string{"iPhoneOS=iOS"},
+		[]string{`\s*\[[^]]*\]$=`, `^(\w+OS) (\d+)\.(\d+) Seed=$1 $2.$3 beta`},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct{ title, want string }{
+		{"iPhoneOS 17.1 (21B74) has been released", "iOS 17.1 (21B74) has been released"},
+		{"macOS 14.2 (23C64) has been released [mirror]", "macOS 14.2 (23C64) has been released"},
+		{"macOS 14.2 Seed 4 (23C5055b) has been released", "macOS 14.2 beta 4 (23C5055b) has been released"},
+		{"iPhoneOS 17.2 Seed 1 (21C5029g) has been released [mirror]", "iOS 17.2 beta 1 (21C5029g) has been released"},
+		{"tvOS 17.1 (21K69) has been released", "tvOS 17.1 (21K69) has been released"},
+	}
+	for _, tt := range tests {
+		if got := rewriteTitle(tt.title, rules); got != tt.want {
+			t.Errorf("rewriteTitle(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+
+	// Splitting into platform, version and build sees the rewritten title,
+	// while the item keeps the original.
+	opts := testNormalizeOptions(t)
+	opts.TitleRewrites = rules
+	it := normalizeItem(rawItem{Title: tests[3].title, PubDate: "Tue, 07 Nov 2023 18:00:00 +0000"}, opts)
+	if it.Title != tests[3].title {
+		t.Errorf("rewritten item: title %q, want the feed's %q", it.Title, tests[3].title)
+	}
+	if it.PlatformKey != "ios" || it.Version != "17.2 beta 1" || it.Build != "21C5029g" || !it.PreRelease {
+		t.Errorf("rewritten item: platform %q version %q build %q pre-release %t, want ios, 17.2 beta 1, 21C5029g, true",
+			it.PlatformKey, it.Version, it.Build, it.PreRelease)
+	}
+
+	for _, bad := range [][2][]string{{{"=x"}, nil}, {{"noequals"}, nil}, {nil, {"(=x"}}, {nil, {"=x"}}} {
+		if _, err := parseTitleRewrites(bad[0], bad[1]); err == nil {
+			t.Errorf("parseTitleRewrites(%q, %q): no error", bad[0], bad[1])
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// sanitize makes s safe to print to a terminal: control characters, such as
+// the ESC that starts an ANSI sequence, are replaced by a visible escape
+// like \x1b so feed content can't move the cursor, recolor the screen or
+// retitle the window. Tabs and newlines are left for the renderers.
+func sanitize(s string) string {
+	if strings.IndexFunc(s, unsafeRune) < 0 {
+		return s
+	}
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case !unsafeRune(r):
+			b.WriteRune(r)
+		case r < 0x100:
+			fmt.Fprintf(&b, `\x%02x`, r)
+		default:
+			fmt.Fprintf(&b, `\u%04x`, r)
+		}
+	}
+	return b.String()
+}
+
+func unsafeRune(r rune) bool {
+	return unicode.IsControl(r) && r != '\t' && r != '\n'
+}
+
+// sanitizeItems returns copies of items with every displayed field passed
+// through sanitize.
+func sanitizeItems(items []Item) []Item {
+	out := make([]Item, len(items))
+	for i, it := range items {
+		for _, s := range []*string{
+			&it.Title, &it.Link, &it.GUID, &it.Description, &it.PlatformLabel,
+			&it.Version, &it.Build, &it.DeviceOrNotes, &it.RawDevice, &it.Notes,
+			&it.DisplayDate, &it.DisplayVersion, &it.Source, &it.UnknownPlatform,
+		} {
+			*s = sanitize(*s)
+		}
+		devices := make([]string, len(it.Devices))
+		for j, d := range it.Devices {
+			devices[j] = sanitize(d)
+		}
+		it.Devices = devices
+		out[i] = it
+	}
+	return out
+}
+
+// sanitizeFeeds is sanitizeItems for feed metadata.
+func sanitizeFeeds(feeds []Feed) []Feed {
+	out := make([]Feed, len(feeds))
+	for i, f := range feeds {
+		f.URL, f.Title, f.Description = sanitize(f.URL), sanitize(f.Title), sanitize(f.Description)
+		out[i] = f
+	}
+	return out
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSanitize(t *testing.T) {
+	for in, want := range map[string]string{
+		"iOS 17.1 (21B74)":        "iOS 17.1 (21B74)",
+		"iOS \x1b[2J17.1":         `iOS \x1b[2J17.1`,
+		"\x1b]0;pwned\x07iOS":     `\x1b]0;pwned\x07iOS`,
+		"tab\tand\nnewline":       "tab\tand\nnewline",
+		"csi \u009b31m":           `csi \x9b31m`,
+		"line sep is not control": "line sep is not control",
+	} {
+		if got := sanitize(in); got != want {
+			t.Errorf("sanitize(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestSanitizeTitleInTable(t *testing.T) {
+	feed := filepath.Join(t.TempDir(), "feed.json")
+	const title = "iOS 17.1.1 (21B91) for iPhone 15\u001b[31m\u001b]0;owned\u0007 has been released"
+	data := `{"version": "https://jsonfeed.org/version/1.1", "title": "t", "items": [
+		{"id": "1", "title": "` + strings.NewReplacer("\u001b", `\u001b`, "\u0007", `\u0007`).Replace(title) + `",
+		 "date_published": "2023-11-07T18:00:00Z"}]}`
+	if err := os.WriteFile(feed, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []string{"table", "porcelain"} {
+		args := []string{"-f", "file://" + feed, "-fields", "date,platform,version,title", "-color", "always", "-no-truncate"}
+		if format == "porcelain" {
+			args = append(args, "-porcelain")
+		}
+		out := runListArgs(t, args...)
+		if !strings.Contains(out, `\x1b]0;owned\x07`) {
+			t.Errorf("%s: title escape not shown as text:\n%s", format, out)
+		}
+		if strings.Contains(out, "\x1b]") || strings.Contains(out, "\x07") {
+			t.Errorf("%s: raw escape from the title reached the output: %q", format, out)
+		}
+	}
+}
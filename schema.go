@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// itemSchema builds a JSON Schema for the items of -format json from the
+// jsonItem struct itself, so the two cannot drift apart. Fields may carry a
+// format tag (e.g. date-time) and an enum tag of comma-separated values.
+func itemSchema() map[string]any {
+	t := reflect.TypeOf(jsonItem{})
+	properties := make(map[string]any, t.NumField())
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		prop := map[string]any{"type": schemaType(field.Type)}
+		if field.Type.Kind() == reflect.Slice {
+			prop["items"] = map[string]any{"type": schemaType(field.Type.Elem())}
+		}
+		if format := field.Tag.Get("format"); format != "" {
+			prop["format"] = format
+		}
+		if enum := field.Tag.Get("enum"); enum != "" {
+			prop["enum"] = strings.Split(enum, ",")
+		}
+		properties[name] = prop
+		if !strings.Contains(opts, "omitempty") {
+			required = append(required, name)
+		}
+	}
+
+	return map[string]any{
+		"title":                "ipsw-timeline item",
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+func schemaType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	default:
+		return "string"
+	}
+}
+
+// renderSchema writes the schema of the JSON output: an array of items.
+func renderSchema(out io.Writer) error {
+	schema := map[string]any{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"title":   "ipsw-timeline -format json output",
+		"type":    "array",
+		"items":   itemSchema(),
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(schema)
+}
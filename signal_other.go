@@ -0,0 +1,11 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// refreshSignals are the signals that make watch refresh immediately. There
+// is no SIGUSR1 outside unix.
+func refreshSignals() []os.Signal {
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// refreshSignals are the signals that make watch refresh immediately.
+func refreshSignals() []os.Signal {
+	return []os.Signal{syscall.SIGUSR1}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// runState is what -state-file remembers between runs: the publish date of
+// the newest released item that has been shown.
+type runState struct {
+	Newest time.Time `json:"newest"`
+}
+
+// loadState reads the state file. A missing file is not an error; it yields
+// the zero state, so nothing is marked new on the first run.
+func loadState(path string) (runState, error) {
+	var st runState
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return st, err
+	}
+	if err := json.Unmarshal(raw, &st); err != nil {
+		return st, err
+	}
+	return st, nil
+}
+
+func saveState(path string, st runState) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	raw, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(raw, '\n'))
+}
+
+// advance returns the state after items have been shown. Expected items
+// carry speculative dates and never move the state forward.
+func (st runState) advance(items []Item) runState {
+	for _, it := range items {
+		if it.Provenance == provenanceExpected {
+			continue
+		}
+		if it.PubDate.After(st.Newest) {
+			st.Newest = it.PubDate
+		}
+	}
+	return st
+}
+
+// newerThanState keeps items published after the recorded state, for
+// -since-last-run. With a zero state, as on the first run, it keeps
+// everything.
+func newerThanState(items []Item, st runState) []Item {
+	if st.Newest.IsZero() {
+		return items
+	}
+	var out []Item
+	for _, it := range items {
+		if it.PubDate.After(st.Newest) {
+			out = append(out, it)
+		}
+	}
+	return out
+}
+
+// markNew flags released items published after the recorded state. With a
+// zero state nothing is flagged.
+func markNew(items []Item, st runState) {
+	if st.Newest.IsZero() {
+		return
+	}
+	for i := range items {
+		if items[i].Provenance != provenanceExpected && items[i].PubDate.After(st.Newest) {
+			items[i].New = true
+		}
+	}
+}
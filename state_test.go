@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSinceLastRun(t *testing.T) {
+	dir := t.TempDir()
+	feed, state := filepath.Join(dir, "feed.rss"), filepath.Join(dir, "state", "last.json")
+	full := string(readFixture(t, "timeline.rss"))
+	// Until the first two releases are out, the feed starts at watchOS.
+	start, end := strings.Index(full, "<item>"), strings.Index(full, "<item>\n<title>watchOS")
+	older := full[:start] + full[end:]
+	write := func(body string) {
+		t.Helper()
+		if err := os.WriteFile(feed, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run := func() []string {
+		t.Helper()
+		out := runListArgs(t, "-f", "file://"+feed, "-state-file", state, "-since-last-run", "-porcelain", "-include-prerelease")
+		var builds []string
+		for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+			if fields := strings.Split(line, "\t"); len(fields) > 4 {
+				builds = append(builds, fields[4])
+			}
+		}
+		return builds
+	}
+
+	write(older)
+	if got := run(); strings.Join(got, ",") != "21S71,21B74,21K69" {
+		t.Errorf("first run, no state file: %q, want every item", got)
+	}
+	if got := run(); len(got) != 0 {
+		t.Errorf("second run, nothing new: %q, want no items", got)
+	}
+	write(full)
+	if got := run(); strings.Join(got, ",") != "21B91,23C5041e" {
+		t.Errorf("after two releases: %q, want just those", got)
+	}
+	st, err := loadState(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "2023-11-07T18:00:00Z"; st.Newest.UTC().Format("2006-01-02T15:04:05Z") != want {
+		t.Errorf("state records %v, want %s", st.Newest, want)
+	}
+}
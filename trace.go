@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http/httptrace"
+	"os"
+	"time"
+)
+
+// withTrace returns ctx set up to log the stages of requests made with it
+// to stderr for -trace: DNS lookup, connecting, the TLS handshake and the
+// first response byte, each with the time since the request started. Lines
+// are printed as the stages finish, so a stuck fetch shows where it stopped.
+func withTrace(ctx context.Context, url string) context.Context {
+	start := time.Now()
+	logf := func(format string, args ...any) {
+		fmt.Fprintf(os.Stderr, "trace: %s: %s (%s)\n", url, fmt.Sprintf(format, args...), time.Since(start).Round(time.Microsecond))
+	}
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart: func(info httptrace.DNSStartInfo) {
+			logf("resolving %s", info.Host)
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			if info.Err != nil {
+				logf("dns failed: %v", info.Err)
+				return
+			}
+			logf("resolved to %d addresses", len(info.Addrs))
+		},
+		ConnectDone: func(network, addr string, err error) {
+			if err != nil {
+				logf("connect to %s failed: %v", addr, err)
+				return
+			}
+			logf("connected to %s", addr)
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			if err != nil {
+				logf("tls handshake failed: %v", err)
+				return
+			}
+			logf("tls handshake done (%s)", tls.VersionName(state.Version))
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				logf("reusing connection to %s", info.Conn.RemoteAddr())
+			}
+		},
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			if info.Err == nil {
+				logf("request sent")
+			}
+		},
+		GotFirstResponseByte: func() {
+			logf("first response byte")
+		},
+	})
+}
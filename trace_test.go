@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTraceStages(t *testing.T) {
+	body := readFixture(t, "timeline.rss")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	}))
+	defer srv.Close()
+	// Going through localhost makes the fetch resolve a name.
+	url := strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)
+
+	cfg := testConfig(t.TempDir())
+	cfg.Trace = true
+	f := newFetcher(cfg)
+	log := captureStderr(t, func() {
+		for range 2 {
+			if _, err := loadItems(f, url, testNormalizeOptions(t)); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+	for _, want := range []string{
+		"trace: " + url + ": resolving localhost (",
+		": resolved to ",
+		": connected to 127.0.0.1:",
+		": request sent (",
+		": first response byte (",
+		": reusing connection to 127.0.0.1:",
+	} {
+		if !strings.Contains(log, want) {
+			t.Errorf("trace log lacks %q:\n%s", want, log)
+		}
+	}
+	if n := strings.Count(log, ": first response byte"); n != 2 {
+		t.Errorf("first response byte logged %d times, want once per request:\n%s", n, log)
+	}
+
+	cfg.Quiet = true
+	if quiet := captureStderr(t, func() { loadItems(newFetcher(cfg), url, testNormalizeOptions(t)) }); quiet != "" {
+		t.Errorf("-trace with -quiet logged:\n%s", quiet)
+	}
+}
+
+func TestTraceTLSHandshake(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	log := captureStderr(t, func() {
+		req, err := http.NewRequestWithContext(withTrace(context.Background(), srv.URL), "GET", srv.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	})
+	if !strings.Contains(log, ": tls handshake done (TLS 1.3) (") {
+		t.Errorf("trace log lacks the TLS handshake:\n%s", log)
+	}
+}
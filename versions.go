@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// releaseVersion is the numeric part of a version, such as [17 1 2] for
+// "17.1.2 beta 3". Missing components count as 0, so 17 == 17.0.
+type releaseVersion []int
+
+// parseReleaseVersion reads the dotted numbers at the start of version.
+func parseReleaseVersion(version string) (releaseVersion, bool) {
+	fields := strings.Fields(version)
+	if len(fields) == 0 {
+		return nil, false
+	}
+	var v releaseVersion
+	for _, part := range strings.Split(strings.TrimRight(fields[0], "."), ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, false
+		}
+		v = append(v, n)
+	}
+	return v, true
+}
+
+func (v releaseVersion) less(u releaseVersion) bool {
+	for i := 0; i < max(len(v), len(u)); i++ {
+		var a, b int
+		if i < len(v) {
+			a = v[i]
+		}
+		if i < len(u) {
+			b = u[i]
+		}
+		if a != b {
+			return a < b
+		}
+	}
+	return false
+}
+
+// parseMinVersions reads -min-version: comma-separated minimums, each either
+// bare ("17", for every platform) or for one platform ("macos=14"). A
+// platform entry wins over a bare one.
+func parseMinVersions(entries []string) (map[string]releaseVersion, error) {
+	return parsePlatformMap("min-version", entries, "a version like 17 or platform=17.1", func(s string) (releaseVersion, bool) {
+		if len(strings.Fields(s)) != 1 {
+			return nil, false
+		}
+		return parseReleaseVersion(s)
+	})
+}
+
+// filterMinVersions keeps items whose version is at least the minimum given
+// for their platform, or the "*" minimum. Platforms without a minimum are
+// kept as they are; items whose version can't be read are dropped, since
+// they can't be compared.
+func filterMinVersions(items []Item, mins map[string]releaseVersion) []Item {
+	return filterPerPlatform(items, mins, func(it Item, floor releaseVersion) bool {
+		v, ok := parseReleaseVersion(it.Version)
+		return ok && !v.less(floor)
+	})
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMinVersions(t *testing.T) {
+	got, err := parseMinVersions(splitList("ios=17, MacOS = 14.1 ,16"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]releaseVersion{"ios": {17}, "macos": {14, 1}, "*": {16}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseMinVersions = %v, want %v", got, want)
+	}
+
+	if got, err := parseMinVersions(nil); got != nil || err != nil {
+		t.Errorf("parseMinVersions(nil) = %v, %v; want nil, nil", got, err)
+	}
+	for _, bad := range []string{"ios=", "=17", "ios=x", "ios=17 beta", "ios=-1"} {
+		_, err := parseMinVersions([]string{bad})
+		if err == nil || !strings.Contains(err.Error(), "invalid min-version entry") {
+			t.Errorf("parseMinVersions(%q) error = %v, want an invalid entry error", bad, err)
+		}
+	}
+}
+
+func TestFilterMinVersions(t *testing.T) {
+	const date = "Tue, 07 Nov 2023 18:00:00 +0000"
+	items := []Item{
+		newItem(t, "iOS 17.1.1 (21B91) has been released", date),
+		newItem(t, "iOS 16.7.2 (20H115) has been released", date),
+		newItem(t, "iOS 17 beta 3 (21A5277h) has been released", date),
+		newItem(t, "macOS 14.1 (23B74) has been released", date),
+		newItem(t, "macOS 13.6.1 (22G313) has been released", date),
+		newItem(t, "macOS Sonoma (23A344) has been released", date),
+		newItem(t, "watchOS 9.6.3 (20U502) has been released", date),
+	}
+	mins, err := parseMinVersions([]string{"ios=17", "macos=14"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, it := range filterMinVersions(items, mins) {
+		got = append(got, it.PlatformLabel+" "+it.Version)
+	}
+	// The unreadable "Sonoma" is dropped, and watchOS has no minimum.
+	want := []string{"iOS 17.1.1", "iOS 17 beta 3", "macOS 14.1", "watchOS 9.6.3"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("filterMinVersions = %v, want %v", got, want)
+	}
+}
+
+func TestReleaseVersionLess(t *testing.T) {
+	tests := []struct {
+		a, b string
+		less bool
+	}{
+		{"16.7.2", "17", true},
+		{"17", "17.0", false},
+		{"17.0", "17", false},
+		{"17.0", "17.0.1", true},
+		{"17.0.1", "17.1", true},
+		{"17.10", "17.9", false},
+	}
+	for _, tt := range tests {
+		a, _ := parseReleaseVersion(tt.a)
+		b, _ := parseReleaseVersion(tt.b)
+		if got := a.less(b); got != tt.less {
+			t.Errorf("%s < %s = %v, want %v", tt.a, tt.b, got, tt.less)
+		}
+	}
+}
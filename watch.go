@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"time"
+)
+
+// runWatch polls the feed every cfg.Interval and redraws the table whenever
+// the selected items change. Fetch and parse errors are reported and the
+// loop keeps going, so a flaky network doesn't end the session. With
+// -refresh-on-signal, SIGUSR1 polls and redraws at once and restarts the
+// interval. With -verbose, items that change between polls (same GUID,
+// different fields) are logged to stderr, which catches silent respins.
+func runWatch(cfg Config) {
+	opts := tableOptions(cfg)
+	clear := isTTY()
+
+	f := newFetcher(cfg)
+
+	var refresh chan os.Signal
+	if cfg.RefreshOnSignal {
+		refresh = make(chan os.Signal, 1)
+		signal.Notify(refresh, refreshSignals()...)
+	}
+
+	var last []string
+	var previous map[string]Item
+	for {
+		items, err := loadFeeds(f, cfg)
+		if err = allowEmpty(err); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		} else {
+			if cfg.Verbose {
+				logChanges(previous, items, os.Stderr)
+			}
+			previous = itemsByID(items)
+			selected := selectItems(items, cfg)
+			keys := itemKeys(selected)
+			if last == nil || !slices.Equal(keys, last) {
+				if clear {
+					fmt.Fprint(os.Stdout, "\033[H\033[2J")
+				}
+				if len(selected) > 0 {
+					renderTable(selected, opts, os.Stdout)
+				} else if cfg.EmptyMessage != "" {
+					fmt.Fprintln(os.Stdout, cfg.EmptyMessage)
+				}
+				last = keys
+			}
+		}
+		if waitForRefresh(cfg.Interval, refresh) {
+			last = nil
+		}
+	}
+}
+
+// waitForRefresh sleeps for interval or until a signal arrives on refresh,
+// whichever comes first, and reports whether it was woken by a signal.
+// Signals received while the previous refresh was in flight are dropped
+// first, so refreshes never pile up.
+func waitForRefresh(interval time.Duration, refresh chan os.Signal) bool {
+	select {
+	case <-refresh:
+	default:
+	}
+
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return false
+	case <-refresh:
+		return true
+	}
+}
+
+func itemsByID(items []Item) map[string]Item {
+	byID := make(map[string]Item, len(items))
+	for _, it := range items {
+		byID[itemID(it)] = it
+	}
+	return byID
+}
+
+// logChanges prints a line per field that changed for items present in both
+// the previous poll and this one. Nothing is logged on the first poll.
+func logChanges(previous map[string]Item, items []Item, out io.Writer) {
+	if previous == nil {
+		return
+	}
+	stamp := time.Now().Format("15:04:05")
+	for _, it := range items {
+		old, ok := previous[itemID(it)]
+		if !ok {
+			continue
+		}
+		for _, c := range itemChanges(old, it) {
+			fmt.Fprintf(out, "%s %s %s: %s changed from %q to %q\n", stamp, platformLabelForKey(summaryKey(it)), sanitize(it.Version), c.field, c.from, c.to)
+		}
+	}
+}
+
+// fieldChange is one field of an item that differs between two polls.
+type fieldChange struct {
+	field, from, to string
+}
+
+// itemChanges compares the normalized fields of two versions of an item.
+// The title isn't compared itself: any change to it shows up in the fields
+// parsed from it.
+func itemChanges(old, cur Item) []fieldChange {
+	fields := []fieldChange{
+		{"platform", old.PlatformKey, cur.PlatformKey},
+		{"version", old.Version, cur.Version},
+		{"build", old.Build, cur.Build},
+		{"device", old.RawDevice, cur.RawDevice},
+		{"notes", old.Notes, cur.Notes},
+		{"link", old.Link, cur.Link},
+		{"date", old.DisplayDate, cur.DisplayDate},
+	}
+	var changes []fieldChange
+	for _, c := range fields {
+		if c.from != c.to {
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+func itemKeys(items []Item) []string {
+	keys := make([]string, 0, len(items))
+	for _, it := range items {
+		keys = append(keys, itemID(it)+"\x00"+it.Title)
+	}
+	return keys
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestLogChangesAcrossCycles(t *testing.T) {
+	silence(t)
+	body := readFixture(t, "timeline.rss")
+	respun := bytes.Replace(body, []byte("(21B91)"), []byte("(21B92)"), 1)
+	var cycle atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cycle.Load() == 0 {
+			w.Write(body)
+			return
+		}
+		w.Write(respun)
+	}))
+	defer srv.Close()
+
+	cfg := testConfig(t.TempDir())
+	cfg.Feeds = []string{srv.URL}
+	cfg.FeedFormat = "auto"
+	f := newFetcher(cfg)
+
+	var log strings.Builder
+	var previous map[string]Item
+	for n := int32(0); n < 2; n++ {
+		cycle.Store(n)
+		items, err := loadFeeds(f, cfg)
+		if err != nil {
+			t.Fatalf("cycle %d: %v", n, err)
+		}
+		logChanges(previous, items, &log)
+		if n == 0 && log.Len() > 0 {
+			t.Errorf("first cycle logged %q", log.String())
+		}
+		previous = itemsByID(items)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(log.String(), "\n"), "\n")
+	if len(lines) != 1 || !strings.HasSuffix(lines[0], ` iOS 17.1.1: build changed from "21B91" to "21B92"`) {
+		t.Errorf("second cycle logged %q, want one build change", log.String())
+	}
+}